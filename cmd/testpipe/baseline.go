@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/krishicks/testpipe"
+)
+
+// baseline records findings to leave unreported, so that testpipe can be
+// adopted for pipelines without fixing everything they are found to do
+// wrong first. Findings are keyed by where they are and what rule found
+// them rather than by line, so the baseline survives edits to the pipeline.
+type baseline struct {
+	Findings []baselineEntry `json:"findings"`
+}
+
+type baselineEntry struct {
+	Pipeline string `json:"pipeline"`
+	Job      string `json:"job"`
+	Task     string `json:"task"`
+	Rule     string `json:"rule"`
+	Count    int    `json:"count"`
+}
+
+type baselineKey struct {
+	pipeline string
+	job      string
+	task     string
+	rule     string
+}
+
+func baselineKeyOf(finding testpipe.Finding) baselineKey {
+	return baselineKey{
+		pipeline: relativePath(finding.PipelinePath),
+		job:      finding.JobName,
+		task:     finding.TaskName,
+		rule:     finding.Rule,
+	}
+}
+
+// newBaseline returns a baseline of the findings of results.
+func newBaseline(results []result) baseline {
+	counts := map[baselineKey]int{}
+	for _, r := range results {
+		for _, finding := range r.findings {
+			counts[baselineKeyOf(finding)]++
+		}
+	}
+
+	result := baseline{Findings: []baselineEntry{}}
+	for key, count := range counts {
+		result.Findings = append(result.Findings, baselineEntry{
+			Pipeline: key.pipeline,
+			Job:      key.job,
+			Task:     key.task,
+			Rule:     key.rule,
+			Count:    count,
+		})
+	}
+
+	sort.Slice(result.Findings, func(i, j int) bool {
+		a, b := result.Findings[i], result.Findings[j]
+		if a.Pipeline != b.Pipeline {
+			return a.Pipeline < b.Pipeline
+		}
+		if a.Job != b.Job {
+			return a.Job < b.Job
+		}
+		if a.Task != b.Task {
+			return a.Task < b.Task
+		}
+		return a.Rule < b.Rule
+	})
+
+	return result
+}
+
+func readBaseline(path string) (baseline, error) {
+	var b baseline
+
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return b, err
+	}
+
+	err = json.Unmarshal(bs, &b)
+	return b, err
+}
+
+func writeBaseline(path string, b baseline) error {
+	bs, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(bs, '\n'), 0644)
+}
+
+// filter removes the findings the baseline records from results. When there
+// are more findings for a pipeline, job, task and rule than it records, the
+// later ones are kept as new.
+func (b baseline) filter(results []result) []result {
+	remaining := map[baselineKey]int{}
+	for _, entry := range b.Findings {
+		remaining[baselineKey{entry.Pipeline, entry.Job, entry.Task, entry.Rule}] += entry.Count
+	}
+
+	filtered := make([]result, len(results))
+	for i, r := range results {
+		filtered[i] = r
+		filtered[i].findings = nil
+
+		for _, finding := range r.findings {
+			key := baselineKeyOf(finding)
+			if remaining[key] > 0 {
+				remaining[key]--
+				continue
+			}
+
+			filtered[i].findings = append(filtered[i].findings, finding)
+		}
+	}
+
+	return filtered
+}
+
+// relativePath makes paths under the working directory relative, so that
+// they are the same wherever the working directory is checked out.
+func relativePath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+
+	return filepath.ToSlash(path)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// diffOp is a line that is kept (' '), removed ('-') or added ('+').
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// writeUnifiedDiff writes the changes from a to b as a unified diff.
+func writeUnifiedDiff(w io.Writer, path string, a, b []byte) error {
+	ops := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", path, path); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// A hunk runs from a change until there are more unchanged lines than
+		// can be shown around two changes.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = next
+		}
+
+		if err := writeHunk(w, ops, start, end); err != nil {
+			return err
+		}
+
+		i = end
+	}
+
+	return nil
+}
+
+func writeHunk(w io.Writer, ops []diffOp, start, end int) error {
+	aStart, bStart := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			aStart++
+		}
+		if op.kind != '-' {
+			bStart++
+		}
+	}
+
+	var aLen, bLen int
+	var body strings.Builder
+	for _, op := range ops[start:end] {
+		if op.kind != '+' {
+			aLen++
+		}
+		if op.kind != '-' {
+			bLen++
+		}
+
+		body.WriteByte(op.kind)
+		body.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			body.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+
+	// An empty range starts at the line before it, as in diff -u.
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+
+	_, err := fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n%s", aStart, aLen, bStart, bLen, body.String())
+	return err
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffLines returns the operations turning a into b, keeping the longest
+// common subsequence of their lines. Lines shared by the start and end of
+// both are matched up first, since fixes change little of a file.
+func diffLines(a, b []string) []diffOp {
+	var prefix, suffix []diffOp
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, diffOp{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]diffOp{{' ', a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := prefix
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+
+	return append(ops, suffix...)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/krishicks/testpipe"
+)
+
+// result is the outcome of linting a single pipeline.
+type result struct {
+	path     string
+	subjects []testpipe.Subject
+	findings []testpipe.Finding
+	varsUsed []string
+	err      error
+}
+
+func writeText(w io.Writer, results []result) error {
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Fprintf(w, "%s\n", r.err.Error())
+			continue
+		}
+
+		for _, finding := range r.findings {
+			fmt.Fprintf(w, "%s\n", finding)
+		}
+	}
+
+	return nil
+}
+
+type jsonReport struct {
+	Findings []testpipe.Finding `json:"findings"`
+	Errors   []jsonError        `json:"errors"`
+}
+
+type jsonError struct {
+	PipelinePath string `json:"pipeline"`
+	Message      string `json:"message"`
+}
+
+func writeJSON(w io.Writer, results []result) error {
+	report := jsonReport{
+		Findings: []testpipe.Finding{},
+		Errors:   []jsonError{},
+	}
+
+	for _, r := range results {
+		if r.err != nil {
+			report.Errors = append(report.Errors, jsonError{
+				PipelinePath: r.path,
+				Message:      r.err.Error(),
+			})
+			continue
+		}
+
+		for _, finding := range r.findings {
+			if finding.Extras == nil {
+				finding.Extras = []string{}
+			}
+			if finding.Missing == nil {
+				finding.Missing = []string{}
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
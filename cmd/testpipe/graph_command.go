@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/krishicks/testpipe"
+)
+
+// graphCommand prints how resources flow between the jobs of pipelines.
+type graphCommand struct {
+	opts *opts
+
+	pipelineOpts
+}
+
+type jsonGraph struct {
+	PipelinePath string `json:"pipeline"`
+	testpipe.Graph
+}
+
+// Execute implements go-flag's Commander interface
+func (c *graphCommand) Execute(args []string) error {
+	if c.opts.Format != "text" && c.opts.Format != "json" {
+		return fmt.Errorf("the graph command can only output text or json, not %s", c.opts.Format)
+	}
+
+	config := c.config()
+
+	var graphs []jsonGraph
+	for _, path := range c.pipelinePaths() {
+		t := testpipe.New(path, config)
+		if _, err := t.Run(); err != nil {
+			log.Fatalf("Failed reading pipeline: %s", err)
+		}
+
+		graphs = append(graphs, jsonGraph{PipelinePath: path, Graph: t.Graph()})
+	}
+
+	out := c.opts.output(os.Stdout)
+
+	var err error
+	if c.opts.Format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(graphs)
+	} else {
+		for _, graph := range graphs {
+			if err = writeDOT(out, graph.PipelinePath, graph.Graph); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Fatalf("Failed writing output: %s", err)
+	}
+
+	return out.Close()
+}
+
+// writeDOT writes a graph in the DOT language. Gets without trigger: true
+// are drawn dashed, as in the Concourse UI.
+func writeDOT(w io.Writer, name string, graph testpipe.Graph) error {
+	id := func(node testpipe.GraphNode) string {
+		return fmt.Sprintf("%q", node.Kind+":"+node.Name)
+	}
+
+	if _, err := fmt.Fprintf(w, "digraph %q {\n", name); err != nil {
+		return err
+	}
+
+	for _, node := range graph.Nodes {
+		shape := "ellipse"
+		if node.Kind == "resource" {
+			shape = "box"
+		}
+
+		if _, err := fmt.Fprintf(w, "  %s [label=%q shape=%s];\n", id(node), node.Name, shape); err != nil {
+			return err
+		}
+	}
+
+	for _, edge := range graph.Edges {
+		style := "solid"
+		if edge.To.Kind == "job" && !edge.Trigger {
+			style = "dashed"
+		}
+
+		if _, err := fmt.Fprintf(w, "  %s -> %s [label=%q style=%s];\n", id(edge.From), id(edge.To), edge.Resource, style); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "}\n")
+	return err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/krishicks/testpipe"
+)
+
+// initCommand generates a config file with the resource map that pipelines
+// need to load their files, along with the git resources found checked out
+// in the workspace when one is given.
+type initCommand struct {
+	opts *opts
+
+	pipelineOpts
+}
+
+type initConfig struct {
+	ResourceMap map[string]string `yaml:"resource_map"`
+}
+
+// Execute implements go-flag's Commander interface
+func (c *initCommand) Execute(args []string) error {
+	config := c.config()
+
+	generated := initConfig{ResourceMap: map[string]string{}}
+	for name, path := range config.ResourceMap {
+		generated.ResourceMap[name] = path
+	}
+
+	for _, path := range c.pipelinePaths() {
+		t := testpipe.New(path, config)
+		if _, err := t.Run(); err != nil {
+			log.Fatalf("Failed reading pipeline: %s", err)
+		}
+
+		for name, path := range t.ResourceMap() {
+			if _, ok := generated.ResourceMap[name]; !ok {
+				generated.ResourceMap[name] = path
+			}
+		}
+
+		for _, name := range t.FileResources() {
+			if _, ok := generated.ResourceMap[name]; !ok {
+				generated.ResourceMap[name] = resourceDir(config.Workspace, name)
+			}
+		}
+	}
+
+	out := c.opts.output(os.Stdout)
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if _, err := io.WriteString(out, "---\n"); err != nil {
+		log.Fatalf("Failed writing output: %s", err)
+	}
+	if err := encoder.Encode(generated); err != nil {
+		log.Fatalf("Failed writing output: %s", err)
+	}
+	if err := encoder.Close(); err != nil {
+		log.Fatalf("Failed writing output: %s", err)
+	}
+
+	return out.Close()
+}
+
+// resourceDir guesses where a resource is checked out: a directory of the
+// same name in the workspace, or in the working directory when there is no
+// workspace. It returns an empty path, to be filled in by hand, when there is
+// none.
+func resourceDir(workspace string, name string) string {
+	abs, err := filepath.Abs(filepath.Join(workspace, name))
+	if err != nil {
+		return ""
+	}
+
+	if stat, err := os.Stat(abs); err != nil || !stat.IsDir() {
+		return ""
+	}
+
+	return abs
+}
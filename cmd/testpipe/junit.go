@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/krishicks/testpipe"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr,omitempty"`
+	Contents string `xml:",cdata"`
+}
+
+// writeJUnit writes one testsuite per pipeline and one testcase per linted
+// job step, including those of the pipelines it sets. Findings that don't
+// belong to a linted step get a testcase of their own. Only findings of
+// error severity fail a testcase; the others are written to its output.
+func writeJUnit(w io.Writer, results []result) error {
+	var report junitTestSuites
+
+	for _, r := range results {
+		suite := junitTestSuite{Name: r.path}
+
+		if r.err != nil {
+			suite.Errors = 1
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				ClassName: r.path,
+				Name:      r.path,
+				Error:     &junitMessage{Message: r.err.Error()},
+			})
+		}
+
+		var cases []testpipe.Subject
+		byCase := map[testpipe.Subject][]testpipe.Finding{}
+		for _, subject := range r.subjects {
+			if _, ok := byCase[subject]; !ok {
+				cases = append(cases, subject)
+				byCase[subject] = nil
+			}
+		}
+
+		for _, finding := range r.findings {
+			subject := testpipe.Subject{
+				PipelinePath: finding.PipelinePath,
+				JobName:      finding.JobName,
+				TaskName:     finding.TaskName,
+			}
+			if _, ok := byCase[subject]; !ok {
+				cases = append(cases, subject)
+			}
+			byCase[subject] = append(byCase[subject], finding)
+		}
+
+		for _, subject := range cases {
+			testCase := junitTestCase{
+				ClassName: subject.PipelinePath,
+				Name:      subject.JobName + "/" + subject.TaskName,
+			}
+
+			var messages, rules, contents, output []string
+			for _, finding := range byCase[subject] {
+				if finding.Severity != testpipe.SeverityError {
+					output = append(output, finding.String())
+					continue
+				}
+
+				messages = append(messages, finding.Detail)
+				rules = append(rules, finding.Rule)
+				contents = append(contents, finding.String())
+			}
+
+			if len(messages) > 0 {
+				testCase.Failure = &junitMessage{
+					Message:  strings.Join(messages, "; "),
+					Type:     strings.Join(rules, ","),
+					Contents: strings.Join(contents, "\n"),
+				}
+				suite.Failures++
+			}
+
+			testCase.SystemOut = strings.Join(output, "\n")
+
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+
+		suite.Tests = len(suite.TestCases)
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/krishicks/testpipe"
+)
+
+// lintCommand lints pipelines, which is what testpipe does when no command
+// is given.
+type lintCommand struct {
+	opts *opts
+
+	pipelineOpts
+
+	Jobs int `long:"jobs" short:"j" value-name:"N" description:"Number of pipelines to lint at once (default: number of CPUs)"`
+
+	Baseline      FileFlag `long:"baseline" value-name:"PATH" description:"Path to a baseline of findings to leave unreported"`
+	WriteBaseline string   `long:"write-baseline" value-name:"PATH" description:"Path to write a baseline of the current findings to, leaving them unreported"`
+
+	Fix    bool `long:"fix" description:"Fix the findings that can be fixed automatically by rewriting pipelines"`
+	DryRun bool `long:"dry-run" description:"Print the changes --fix would make as a unified diff instead of making them"`
+}
+
+// Execute implements go-flag's Commander interface
+func (c *lintCommand) Execute(args []string) error {
+	// The diff printed with --dry-run would be mixed in with a report also
+	// written to stdout.
+	if c.DryRun && c.opts.Format != "text" && c.opts.OutputPath == "" {
+		return fmt.Errorf("--dry-run prints a diff to stdout, so --format %s needs --output", c.opts.Format)
+	}
+
+	config := c.config()
+	tasks := testpipe.NewTaskCache()
+
+	// Findings left out by the baseline are still fixed, and the baseline is
+	// only applied to the findings that remain.
+	results := lintAll(c.pipelinePaths(), config, tasks, c.Jobs)
+
+	if c.Fix || c.DryRun {
+		if c.fix(results) && !c.DryRun {
+			results = lintAll(c.pipelinePaths(), config, tasks, c.Jobs)
+		}
+	}
+
+	report(*c.opts, c.applyBaseline(results))
+
+	return nil
+}
+
+// applyBaseline leaves out the findings in the baseline, writing it first
+// when asked to.
+func (c *lintCommand) applyBaseline(results []result) []result {
+	if c.WriteBaseline != "" {
+		err := writeBaseline(c.WriteBaseline, newBaseline(results))
+		if err != nil {
+			log.Fatalf("Failed writing baseline: %s", err)
+		}
+
+		results = newBaseline(results).filter(results)
+	} else if c.Baseline.Path() != "" {
+		b, err := readBaseline(c.Baseline.Path())
+		if err != nil {
+			log.Fatalf("Failed reading baseline: %s", err)
+		}
+
+		results = b.filter(results)
+	}
+
+	return results
+}
+
+// fix fixes what it can of the findings of results, or with --dry-run
+// prints a diff of the fixes to stdout. It returns whether any pipeline was
+// changed.
+func (c *lintCommand) fix(results []result) bool {
+	var paths []string
+	byPath := map[string][]testpipe.Finding{}
+	for _, r := range results {
+		for _, finding := range r.findings {
+			if _, ok := byPath[finding.PipelinePath]; !ok {
+				paths = append(paths, finding.PipelinePath)
+			}
+			byPath[finding.PipelinePath] = append(byPath[finding.PipelinePath], finding)
+		}
+	}
+
+	var changed bool
+	for _, path := range paths {
+		original, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed reading pipeline: %s", err)
+		}
+
+		fixed, err := testpipe.Fix(path, byPath[path])
+		if err != nil {
+			log.Fatalf("Failed fixing pipeline: %s", err)
+		}
+
+		if bytes.Equal(original, fixed) {
+			continue
+		}
+		changed = true
+
+		if c.DryRun {
+			err = writeUnifiedDiff(os.Stdout, relativePath(path), original, fixed)
+			if err != nil {
+				log.Fatalf("Failed writing diff: %s", err)
+			}
+			continue
+		}
+
+		stat, err := os.Stat(path)
+		if err != nil {
+			log.Fatalf("Failed writing pipeline: %s", err)
+		}
+
+		err = ioutil.WriteFile(path, fixed, stat.Mode())
+		if err != nil {
+			log.Fatalf("Failed writing pipeline: %s", err)
+		}
+	}
+
+	return changed
+}
+
+// lintAll lints pipelines with a pool of workers, sharing a cache of task
+// files, and returns their results in the order the pipelines were given.
+func lintAll(paths []string, config testpipe.Config, tasks *testpipe.TaskCache, workers int) []result {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]result, len(paths))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = lint(paths[i], config, tasks)
+			}
+		}()
+	}
+
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+
+	results = withoutDuplicatePipelines(results)
+
+	if config.SharedVars != nil {
+		reportUnusedSharedVars(results, config)
+	}
+
+	return results
+}
+
+// reportUnusedSharedVars adds the vars shared by the pipelines that none of
+// them refer to to the findings of the first pipeline. Nothing is reported
+// when a pipeline couldn't be linted, since its vars aren't known.
+func reportUnusedSharedVars(results []result, config testpipe.Config) {
+	var used []string
+	for _, r := range results {
+		if r.err != nil {
+			return
+		}
+
+		used = append(used, r.varsUsed...)
+	}
+
+	if len(results) > 0 {
+		results[0].findings = append(results[0].findings, testpipe.UnusedSharedVars(config, used, results[0].path)...)
+	}
+}
+
+// withoutDuplicatePipelines leaves out what is reported for a pipeline set
+// by a set_pipeline step when the pipeline is also linted on its own, or is
+// set by a pipeline whose result comes earlier, so that each pipeline is
+// reported once.
+func withoutDuplicatePipelines(results []result) []result {
+	owners := map[string]int{}
+	for i, r := range results {
+		owners[pathKey(r.path)] = i
+	}
+
+	owns := func(i int, path string) bool {
+		key := pathKey(path)
+		if owner, ok := owners[key]; ok {
+			return owner == i
+		}
+
+		owners[key] = i
+		return true
+	}
+
+	deduped := make([]result, len(results))
+	for i, r := range results {
+		deduped[i] = result{path: r.path, varsUsed: r.varsUsed, err: r.err}
+
+		for _, subject := range r.subjects {
+			if owns(i, subject.PipelinePath) {
+				deduped[i].subjects = append(deduped[i].subjects, subject)
+			}
+		}
+
+		for _, finding := range r.findings {
+			if owns(i, finding.PipelinePath) {
+				deduped[i].findings = append(deduped[i].findings, finding)
+			}
+		}
+	}
+
+	return deduped
+}
+
+func lint(path string, config testpipe.Config, tasks *testpipe.TaskCache) result {
+	t := testpipe.New(path, config)
+	t.SetTaskCache(tasks)
+	findings, err := t.Run()
+
+	return result{
+		path:     path,
+		subjects: t.Subjects(),
+		findings: findings,
+		varsUsed: t.VarsUsed(),
+		err:      err,
+	}
+}
@@ -1,47 +1,248 @@
 package main
 
 import (
-	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
-	yaml "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
 
 	flags "github.com/jessevdk/go-flags"
 	"github.com/krishicks/testpipe"
 )
 
 type opts struct {
-	PipelinePath []FileFlag `long:"pipeline" short:"p" value-name:"PATH" description:"Path to pipeline"`
+	Format     string `long:"format" value-name:"FORMAT" default:"text" choice:"text" choice:"json" choice:"junit" choice:"sarif" description:"Output format"`
+	OutputPath string `long:"output" short:"o" value-name:"PATH" description:"Path to write the report to instead of stdout"`
+}
+
+// pipelineOpts are the options of every command that reads pipelines.
+type pipelineOpts struct {
+	PipelinePath []PathFlag `long:"pipeline" short:"p" value-name:"PATH" description:"Path to pipeline, or a directory or glob pattern to search for pipelines"`
 	ConfigPath   FileFlag   `long:"config" short:"c" value-name:"PATH" description:"Path to config"`
+	LoadVarsFrom []FileFlag `long:"load-vars-from" short:"l" value-name:"PATH" description:"Path to a YAML file of vars to interpolate"`
+	Vars         []VarFlag  `long:"var" short:"v" value-name:"[NAME=STRING]" description:"Var to interpolate, overriding those loaded from files"`
+	Workspace    string     `long:"workspace" short:"w" value-name:"DIR" description:"Directory of checked out repositories to map git resources missing from the resource map to"`
 }
 
 func main() {
 	var o opts
-	_, err := flags.Parse(&o)
+	parser := flags.NewParser(&o, flags.Default)
+
+	commands := []struct {
+		name    string
+		short   string
+		long    string
+		command interface{}
+	}{
+		{"lint", "Lint pipelines", "Lint pipelines and the tasks and pipelines they load.", &lintCommand{opts: &o}},
+		{"task", "Lint task files", "Lint task files on their own, as they would be run by fly execute.", &taskCommand{opts: &o}},
+		{"graph", "Print the job graph", "Print how resources flow between the jobs of pipelines, as DOT or, with --format json, as JSON.", &graphCommand{opts: &o}},
+		{"rules", "List the rules", "List the rules that findings are reported under.", &rulesCommand{opts: &o}},
+		{"init", "Generate a config file", "Generate a config file with the resource map pipelines need.", &initCommand{opts: &o}},
+	}
+
+	for _, c := range commands {
+		_, err := parser.AddCommand(c.name, c.short, c.long, c.command)
+		if err != nil {
+			log.Fatalf("error: %s\n", err)
+		}
+	}
+
+	_, err := parser.ParseArgs(defaultToLint(parser, os.Args[1:]))
 	if err != nil {
 		log.Fatalf("error: %s\n", err)
 	}
+}
+
+// defaultToLint keeps command lines from before there were commands working
+// by running lint when no command is given but flags are.
+func defaultToLint(parser *flags.Parser, args []string) []string {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "-") {
+		return args
+	}
+
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" || parser.Find(arg) != nil {
+			return args
+		}
+	}
 
+	return append([]string{"lint"}, args...)
+}
+
+// readConfig loads the config file at path, if one is given.
+func readConfig(path FileFlag) testpipe.Config {
 	var config testpipe.Config
-	if o.ConfigPath.Path() != "" {
-		bs, err := ioutil.ReadFile(o.ConfigPath.Path())
-		if err != nil {
-			log.Fatalf("Failed reading config file: %s", err)
+	if path.Path() == "" {
+		return config
+	}
+
+	bs, err := ioutil.ReadFile(path.Path())
+	if err != nil {
+		log.Fatalf("Failed reading config file: %s", err)
+	}
+	err = yaml.Unmarshal(bs, &config)
+	if err != nil {
+		log.Fatalf("Failed unmarshaling config file: %s", err)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		log.Fatalf("Invalid config file: %s", err)
+	}
+
+	return config
+}
+
+// config loads the config file and vars given to a command.
+func (o pipelineOpts) config() testpipe.Config {
+	config := readConfig(o.ConfigPath)
+
+	if len(o.LoadVarsFrom) > 0 || len(o.Vars) > 0 {
+		vars := map[string]interface{}{}
+		for k, v := range config.Vars {
+			vars[k] = v
 		}
-		err = yaml.Unmarshal(bs, &config)
-		if err != nil {
-			log.Fatalf("Failed unmarshaling config file: %s", err)
+
+		for _, path := range o.LoadVarsFrom {
+			bs, err := ioutil.ReadFile(path.Path())
+			if err != nil {
+				log.Fatalf("Failed reading vars file: %s", err)
+			}
+
+			var fileVars map[string]interface{}
+			err = yaml.Unmarshal(bs, &fileVars)
+			if err != nil {
+				log.Fatalf("Failed unmarshaling vars file %s: %s", path.Path(), err)
+			}
+
+			for k, v := range fileVars {
+				vars[k] = v
+			}
 		}
+
+		for _, v := range o.Vars {
+			vars[v.Name] = v.Value
+		}
+
+		config.Vars = vars
+	}
+
+	// Vars given to several pipelines are shared by them, so that each is
+	// only checked for the vars it refers to. Those that none of them refer
+	// to are reported once all have been linted.
+	if len(o.pipelinePaths()) > 1 {
+		config.SharedVars, config.Vars = config.Vars, nil
 	}
 
-	for i := range o.PipelinePath {
-		t := testpipe.New(o.PipelinePath[i].Path(), config)
-		err = t.Run()
+	if o.Workspace != "" {
+		workspace, err := filepath.Abs(o.Workspace)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-			os.Exit(1)
+			log.Fatalf("Failed resolving workspace: %s", err)
+		}
+		config.Workspace = workspace
+	}
+
+	return config
+}
+
+// pipelinePaths returns the paths of the pipelines given to a command, each
+// only once however its path was written.
+func (o pipelineOpts) pipelinePaths() []string {
+	var pipelinePaths []string
+	seen := map[string]bool{}
+	for _, pipelinePath := range o.PipelinePath {
+		for _, path := range pipelinePath.Paths() {
+			if key := pathKey(path); !seen[key] {
+				seen[key] = true
+				pipelinePaths = append(pipelinePaths, path)
+			}
 		}
 	}
+
+	return pipelinePaths
+}
+
+// pathKey identifies a file however its path was written.
+func pathKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(path)
+}
+
+// output opens where a command's output goes: the file given by --output,
+// or otherwise out.
+func (o opts) output(out io.Writer) io.WriteCloser {
+	if o.OutputPath == "" {
+		return nopCloser{out}
+	}
+
+	file, err := os.Create(o.OutputPath)
+	if err != nil {
+		log.Fatalf("Failed creating output file: %s", err)
+	}
+
+	return file
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// report writes results in the format asked for, exiting non-zero if any
+// failed or has findings of error severity.
+func report(o opts, results []result) {
+	var failed bool
+	for _, r := range results {
+		if r.err != nil {
+			failed = true
+		}
+
+		for _, finding := range r.findings {
+			if finding.Severity == testpipe.SeverityError {
+				failed = true
+			}
+		}
+	}
+
+	var out io.WriteCloser
+	if o.Format == "text" {
+		out = o.output(os.Stderr)
+	} else {
+		out = o.output(os.Stdout)
+	}
+
+	var err error
+	switch o.Format {
+	case "json":
+		err = writeJSON(out, results)
+	case "junit":
+		err = writeJUnit(out, results)
+	case "sarif":
+		err = writeSARIF(out, results)
+	default:
+		err = writeText(out, results)
+	}
+	if err != nil {
+		log.Fatalf("Failed writing output: %s", err)
+	}
+
+	err = out.Close()
+	if err != nil {
+		log.Fatalf("Failed writing output: %s", err)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
 }
@@ -1,6 +1,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -35,6 +36,10 @@ var _ = Describe("Main", func() {
 		pipelinePath = pipelineFile.Name()
 
 		pipelineConfig := `---
+resources:
+- name: a-resource
+  type: git
+
 jobs:
 - name: some-job
   plan:
@@ -98,6 +103,10 @@ run:
 			Expect(err).NotTo(HaveOccurred())
 
 			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+
 jobs:
 - name: some-job
   plan:
@@ -219,6 +228,10 @@ jobs:
 	Context("when the pipeline uses input_mapping to specify a resource that a task requires", func() {
 		BeforeEach(func() {
 			pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: some-resource
+  type: git
+
 jobs:
 - name: some-job
   plan:
@@ -282,6 +295,10 @@ jobs:
 	Context("when the pipeline defines a task inline", func() {
 		BeforeEach(func() {
 			pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: a-resource
+  type: git
+
 jobs:
 - name: some-job
   plan:
@@ -437,6 +454,10 @@ params: {}
 			err = ioutil.WriteFile(taskPath, []byte(taskConfig), os.ModePerm)
 			Expect(err).NotTo(HaveOccurred())
 			pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: some-resource
+  type: git
+
 jobs:
 - name: some-job
   plan:
@@ -493,6 +514,10 @@ params: {}
 			err = ioutil.WriteFile(taskPath, []byte(taskConfig), os.ModePerm)
 			Expect(err).NotTo(HaveOccurred())
 			pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: some-resource
+  type: git
+
 jobs:
 - name: some-job
   plan:
@@ -567,4 +592,2413 @@ jobs:
 			Eventually(session).Should(gexec.Exit(1))
 		})
 	})
+
+	Context("when the pipeline has several violations across jobs", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+jobs:
+- name: some-job
+  plan:
+  - task: some-task
+    params:
+      some_other_param: B
+    config:
+      run:
+        path: some-command
+  - task: some-empty-task
+- name: some-other-job
+  plan:
+  - task: some-other-task
+    config:
+      inputs:
+      - name: a-resource
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports every violation before exiting with error", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session.Err).Should(gbytes.Say("Extra params that should be removed"))
+			Eventually(session.Err).Should(gbytes.Say("some_other_param"))
+			Eventually(session.Err).Should(gbytes.Say("some-job/some-empty-task is missing a definition"))
+			Eventually(session.Err).Should(gbytes.Say("Task invocation is missing resources"))
+			Eventually(session.Err).Should(gbytes.Say("a-resource"))
+
+			Eventually(session).Should(gexec.Exit(1))
+		})
+	})
+
+	Context("when the pipeline passes invalid keys to a step", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: a-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - aggregate:
+    - get: a-resource
+      trigger: true
+      depth: 1
+  - task: some-task
+    input_mapping:
+      a-resource: a-resource
+    inputs:
+    - name: a-resource
+    config:
+      inputs:
+      - name: a-resource
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("exits with error", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session.Err).Should(gbytes.Say("Invalid keys passed to get"))
+			Eventually(session.Err).Should(gbytes.Say("Task:\t\ta-resource"))
+			Eventually(session.Err).Should(gbytes.Say("Extra keys that should be removed"))
+			Eventually(session.Err).Should(gbytes.Say("depth"))
+			Eventually(session.Err).Should(gbytes.Say("Invalid keys passed to task"))
+			Eventually(session.Err).Should(gbytes.Say("inputs"))
+
+			Eventually(session).Should(gexec.Exit(1))
+		})
+	})
+
+	Context("when steps are given keys added in newer versions of Concourse", func() {
+		BeforeEach(func() {
+			pipelineConfig := `---
+resources:
+- name: a-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: a-resource
+  - task: some-task
+    vars:
+      some_var: some-value
+    container_limits:
+      cpu: 512
+      memory: 1GB
+    config:
+      inputs:
+      - name: a-resource
+      run:
+        path: some-command
+  - put: a-resource
+    inputs: [a-resource]
+    no_get: true
+`
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("accepts them", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(string(session.Out.Contents())).NotTo(ContainSubstring("step-keys"))
+		})
+	})
+
+	Context("when the output format is json", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+jobs:
+- name: some-job
+  plan:
+  - task: some-task
+    params:
+      some_other_param: B
+    config:
+      params:
+        some_param:
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("writes the findings as a JSON document to stdout", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			Expect(session.Out.Contents()).To(MatchJSON(fmt.Sprintf(`{
+  "findings": [
+    {
+      "rule": "params-parity",
+      "severity": "error",
+      "kind": "params",
+      "pipeline": %q,
+      "job": "some-job",
+      "task": "some-task",
+      "file": %q,
+      "line": 6,
+      "column": 5,
+      "message": "Params do not have parity",
+      "extras": ["some_other_param"],
+      "missing": ["some_param"]
+    }
+  ],
+  "errors": []
+}`, pipelinePath, pipelinePath)))
+		})
+	})
+
+	Context("when the output format is junit", func() {
+		var reportPath string
+
+		BeforeEach(func() {
+			reportPath = filepath.Join(tmpDir, "report.xml")
+
+			pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: a-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: a-resource
+  - task: some-task
+    params:
+      some_other_param: B
+    config:
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("writes a testcase per job step to the output path", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "junit", "--output", reportPath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			report, err := ioutil.ReadFile(reportPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(report)).To(ContainSubstring(fmt.Sprintf(`<testsuite name="%s" tests="2" failures="1" errors="0">`, pipelinePath)))
+			Expect(string(report)).To(ContainSubstring(`<testcase classname="%s" name="some-job/a-resource"></testcase>`, pipelinePath))
+			Expect(string(report)).To(ContainSubstring(`<failure message="Params do not have parity" type="params-parity">`))
+			Expect(string(report)).To(ContainSubstring("some_other_param"))
+		})
+	})
+
+	Context("when the output format is sarif", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+jobs:
+- name: some-job
+  plan:
+  - task: some-task
+    config:
+      inputs:
+      - name: a-resource
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("writes results pointing at the offending step", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "sarif")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var log struct {
+				Version string `json:"version"`
+				Runs    []struct {
+					Results []struct {
+						RuleID    string `json:"ruleId"`
+						Locations []struct {
+							PhysicalLocation struct {
+								ArtifactLocation struct {
+									URI string `json:"uri"`
+								} `json:"artifactLocation"`
+								Region struct {
+									StartLine   int `json:"startLine"`
+									StartColumn int `json:"startColumn"`
+								} `json:"region"`
+							} `json:"physicalLocation"`
+						} `json:"locations"`
+					} `json:"results"`
+				} `json:"runs"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &log)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(log.Version).To(Equal("2.1.0"))
+			Expect(log.Runs).To(HaveLen(1))
+			Expect(log.Runs[0].Results).To(HaveLen(1))
+
+			result := log.Runs[0].Results[0]
+			Expect(result.RuleID).To(Equal("required-inputs"))
+			Expect(result.Locations[0].PhysicalLocation.ArtifactLocation.URI).To(Equal("file://" + filepath.ToSlash(pipelinePath)))
+			Expect(result.Locations[0].PhysicalLocation.Region.StartLine).To(Equal(5))
+			Expect(result.Locations[0].PhysicalLocation.Region.StartColumn).To(Equal(5))
+		})
+
+		It("uses relative URIs for pipelines under the working directory", func() {
+			cmd := exec.Command(cmdPath, "-p", filepath.Base(pipelinePath), "--format", "sarif")
+			cmd.Dir = filepath.Dir(pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var log struct {
+				Runs []struct {
+					Results []struct {
+						Locations []struct {
+							PhysicalLocation struct {
+								ArtifactLocation struct {
+									URI string `json:"uri"`
+								} `json:"artifactLocation"`
+							} `json:"physicalLocation"`
+						} `json:"locations"`
+					} `json:"results"`
+				} `json:"runs"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &log)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(log.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI).To(Equal(filepath.Base(pipelinePath)))
+		})
+	})
+
+	Context("when a task file loaded through the resource map has no path to run", func() {
+		BeforeEach(func() {
+			someResourceDir := filepath.Join(tmpDir, "some-resource")
+			err := os.MkdirAll(someResourceDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			configFilePath = filepath.Join(tmpDir, "testpipe-config.yml")
+			err = ioutil.WriteFile(configFilePath, []byte(fmt.Sprintf(`---
+resource_map:
+  some-resource: %s`, someResourceDir)), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = ioutil.WriteFile(filepath.Join(someResourceDir, "task.yml"), []byte(`---
+platform: linux
+run:
+  args: [foo]
+`), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - task: some-task
+    file: some-resource/task.yml
+`
+
+			err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("points the finding at the task file", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			Expect(session.Out).To(gbytes.Say(`"file": "%s"`, filepath.Join(tmpDir, "some-resource", "task.yml")))
+			Expect(session.Out).To(gbytes.Say(`"line": 3`))
+			Expect(session.Out).To(gbytes.Say(`"message": "task some-job/some-task is missing a path"`))
+		})
+	})
+
+	Context("when a step refers to a resource that is not declared", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+resource_types:
+- name: some-custom-type
+  type: docker-image
+
+resources:
+- name: some-resource
+  type: some-custom-type
+- name: some-other-resource
+  type: some-undeclared-type
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - get: renamed-resource
+    resource: a-missing-resource
+  - put: another-missing-resource
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("exits with error", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session.Err).Should(gbytes.Say("Resource has a type that is neither built in nor declared"))
+			Eventually(session.Err).Should(gbytes.Say("Task:\t\tsome-other-resource"))
+			Eventually(session.Err).Should(gbytes.Say("some-undeclared-type"))
+			Eventually(session.Err).Should(gbytes.Say("Step refers to a resource that is not declared"))
+			Eventually(session.Err).Should(gbytes.Say("Task:\t\trenamed-resource"))
+			Eventually(session.Err).Should(gbytes.Say("a-missing-resource"))
+			Eventually(session.Err).Should(gbytes.Say("Step refers to a resource that is not declared"))
+			Eventually(session.Err).Should(gbytes.Say("another-missing-resource"))
+
+			Eventually(session).Should(gexec.Exit(1))
+		})
+	})
+
+	Context("when the pipeline declares resources and resource types that are never used", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+resource_types:
+- name: some-image-type
+  type: docker-image
+- name: some-unused-type
+  type: docker-image
+
+resources:
+- name: some-resource
+  type: git
+- name: some-unused-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - task: some-task
+    config:
+      image_resource:
+        type: some-image-type
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("exits with error", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule   string   `json:"rule"`
+					Extras []string `json:"extras"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(2))
+			Expect(report.Findings[0].Rule).To(Equal("unused-resource"))
+			Expect(report.Findings[0].Extras).To(Equal([]string{"some-unused-resource"}))
+			Expect(report.Findings[1].Rule).To(Equal("unused-resource-type"))
+			Expect(report.Findings[1].Extras).To(Equal([]string{"some-unused-type"}))
+		})
+	})
+
+	Context("when a task whose image may use a declared resource type cannot be loaded", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+resource_types:
+- name: some-image-type
+  type: docker-image
+
+resources:
+- name: some-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - task: some-task
+    file: some-resource/task.yml
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not report the resource type as unused", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule string `json:"rule"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(1))
+			Expect(report.Findings[0].Rule).To(Equal("task-definition"))
+		})
+	})
+
+	Context("when a get has passed constraints that cannot be satisfied", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: some-resource
+  type: git
+- name: some-other-resource
+  type: git
+
+jobs:
+- name: some-upstream-job
+  plan:
+  - get: some-other-resource
+  - put: some-resource
+- name: some-unrelated-job
+  plan:
+  - get: some-other-resource
+- name: some-job
+  plan:
+  - get: some-resource
+    passed: [some-upstream-job, some-unrelated-job, some-missing-job]
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("exits with error", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session.Err).Should(gbytes.Say("Get is constrained by jobs that do not exist"))
+			Eventually(session.Err).Should(gbytes.Say("Job:\t\tsome-job"))
+			Eventually(session.Err).Should(gbytes.Say("some-missing-job"))
+			Eventually(session.Err).Should(gbytes.Say("Get is constrained by jobs that do not get or put some-resource"))
+			Eventually(session.Err).Should(gbytes.Say("some-unrelated-job"))
+
+			Eventually(session).Should(gexec.Exit(1))
+		})
+
+		It("does not report jobs that satisfy the constraint", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).NotTo(gbytes.Say("some-upstream-job"))
+		})
+	})
+
+	Context("when tasks are nested in step hooks", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+jobs:
+- name: some-job
+  plan:
+  - task: some-task
+    timeout: 1h
+    config:
+      outputs:
+      - name: some-output
+      run:
+        path: some-command
+    on_failure:
+      task: some-failure-task
+      config:
+        inputs:
+        - name: some-output
+        outputs:
+        - name: some-failure-output
+        run:
+          path: some-command
+    on_success:
+      try:
+        task: some-success-task
+        config:
+          outputs:
+          - name: some-success-output
+          run:
+            path: some-command
+    ensure:
+      task: some-ensure-task
+      params:
+        some_param: A
+      config:
+        run:
+          path: some-command
+  - task: some-downstream-task
+    config:
+      inputs:
+      - name: some-success-output
+      - name: some-failure-output
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lints the hooks with the outputs they can see", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session.Err).Should(gbytes.Say("Params do not have parity"))
+			Eventually(session.Err).Should(gbytes.Say("Task:\t\tsome-ensure-task"))
+			Eventually(session.Err).Should(gbytes.Say("Task invocation is missing resources"))
+			Eventually(session.Err).Should(gbytes.Say("Task:\t\tsome-downstream-task"))
+			Eventually(session.Err).Should(gbytes.Say("Missing resources that should be added:\n\\s+some-failure-output\n"))
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).NotTo(gbytes.Say("some-failure-task"))
+		})
+	})
+
+	Context("when tasks rely on what a try step or a step guarded by ensure produces", func() {
+		BeforeEach(func() {
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+- name: some-other-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - try:
+      get: some-other-resource
+  - task: some-task
+    config:
+      inputs:
+      - name: some-other-resource
+      run:
+        path: some-command
+  - task: some-producing-task
+    config:
+      outputs:
+      - name: some-output
+      run:
+        path: some-command
+    ensure:
+      task: some-ensure-task
+      config:
+        inputs:
+        - name: some-resource
+        - name: some-output
+        run:
+          path: some-command
+  - task: some-downstream-task
+    config:
+      inputs:
+      - name: some-output
+      run:
+        path: some-command
+`
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports the resources that may not have been produced", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule    string   `json:"rule"`
+					Task    string   `json:"task"`
+					Missing []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(2))
+			Expect(report.Findings[0].Rule).To(Equal("hook-inputs"))
+			Expect(report.Findings[0].Task).To(Equal("some-task"))
+			Expect(report.Findings[0].Missing).To(Equal([]string{"some-other-resource"}))
+			Expect(report.Findings[1].Rule).To(Equal("hook-inputs"))
+			Expect(report.Findings[1].Task).To(Equal("some-ensure-task"))
+			Expect(report.Findings[1].Missing).To(Equal([]string{"some-output"}))
+		})
+	})
+
+	Context("when a job has hooks that need resources from its plan", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: some-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - task: some-build-task
+    config:
+      outputs:
+      - name: some-output
+      run:
+        path: some-command
+  on_success:
+    task: some-success-task
+    config:
+      inputs:
+      - name: some-output
+      run:
+        path: some-command
+  on_failure:
+    task: some-failure-task
+    config:
+      inputs:
+      - name: some-missing-resource
+      run:
+        path: some-command
+  ensure:
+    task: some-cleanup-task
+    config:
+      inputs:
+      - name: some-resource
+      - name: some-output
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lints the hooks with the resources the plan may have produced", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule    string   `json:"rule"`
+					Task    string   `json:"task"`
+					Missing []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(2))
+			Expect(report.Findings[0].Rule).To(Equal("required-inputs"))
+			Expect(report.Findings[0].Task).To(Equal("some-failure-task"))
+			Expect(report.Findings[0].Missing).To(Equal([]string{"some-missing-resource"}))
+			Expect(report.Findings[1].Rule).To(Equal("hook-inputs"))
+			Expect(report.Findings[1].Task).To(Equal("some-cleanup-task"))
+			Expect(report.Findings[1].Missing).To(Equal([]string{"some-output"}))
+		})
+	})
+
+	Context("when a task in an aggregate relies on the output of a sibling", func() {
+		BeforeEach(func() {
+			pipelineConfig := fmt.Sprintf(`---
+jobs:
+- name: some-job
+  plan:
+  - aggregate:
+    - do:
+      - task: some-upstream-task
+        config:
+          outputs:
+          - name: some-output
+          run:
+            path: some-command
+      - task: some-task
+        config:
+          inputs:
+          - name: some-output
+          run:
+            path: some-command
+    - task: some-sibling-task
+      config:
+        inputs:
+        - name: some-output
+        run:
+          path: some-command
+  - task: some-downstream-task
+    config:
+      inputs:
+      - name: some-output
+      run:
+        path: some-command
+`)
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports only the task relying on its sibling", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule    string   `json:"rule"`
+					Task    string   `json:"task"`
+					Missing []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(1))
+			Expect(report.Findings[0].Rule).To(Equal("parallel-inputs"))
+			Expect(report.Findings[0].Task).To(Equal("some-sibling-task"))
+			Expect(report.Findings[0].Missing).To(Equal([]string{"some-output"}))
+		})
+	})
+
+	Context("when the pipeline uses in_parallel, set_pipeline, load_var and across", func() {
+		BeforeEach(func() {
+			ciDir := filepath.Join(tmpDir, "ci")
+			err := os.MkdirAll(ciDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = ioutil.WriteFile(filepath.Join(ciDir, "version"), []byte("1.0.0"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			testpipeConfig := fmt.Sprintf(`---
+resource_map:
+  ci: %s`, ciDir)
+
+			configFilePath = filepath.Join(tmpDir, "testpipe-config.yml")
+			err = ioutil.WriteFile(configFilePath, []byte(testpipeConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelineConfig := `---
+resources:
+- name: ci
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - in_parallel:
+      limit: 2
+      steps:
+      - get: ci
+      - task: some-sibling-task
+        config:
+          inputs:
+          - name: ci
+          run:
+            path: some-command
+  - in_parallel:
+    - load_var: version
+      file: ci/version
+  - across:
+    - var: env
+      values: [staging, production]
+    set_pipeline: some-pipeline
+    file: ci/missing-pipeline.yml
+    vars:
+      env: ((.:env))
+      version: ((.:version))
+      tag: ((.:tag))
+`
+
+			err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lints the new steps and their nested plans", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule    string   `json:"rule"`
+					Task    string   `json:"task"`
+					Missing []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(3))
+			Expect(report.Findings[0].Rule).To(Equal("parallel-inputs"))
+			Expect(report.Findings[0].Task).To(Equal("some-sibling-task"))
+			Expect(report.Findings[0].Missing).To(Equal([]string{"ci"}))
+			Expect(report.Findings[1].Rule).To(Equal("local-vars"))
+			Expect(report.Findings[1].Task).To(Equal("some-pipeline"))
+			Expect(report.Findings[1].Missing).To(Equal([]string{"tag"}))
+			Expect(report.Findings[2].Rule).To(Equal("step-files"))
+			Expect(report.Findings[2].Task).To(Equal("some-pipeline"))
+			Expect(report.Findings[2].Missing).To(Equal([]string{"ci/missing-pipeline.yml"}))
+		})
+	})
+
+	Context("when a job sets a pipeline found through the resource map", func() {
+		var childPath string
+
+		BeforeEach(func() {
+			ciDir := filepath.Join(tmpDir, "ci")
+			err := os.MkdirAll(ciDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			testpipeConfig := fmt.Sprintf(`---
+resource_map:
+  ci: %s`, ciDir)
+
+			configFilePath = filepath.Join(tmpDir, "testpipe-config.yml")
+			err = ioutil.WriteFile(configFilePath, []byte(testpipeConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			childPath = filepath.Join(ciDir, "child.yml")
+			childConfig := `---
+jobs:
+- name: child-job
+  plan:
+  - task: child-task
+    config:
+      params:
+        BRANCH: ((branch))
+        TOKEN: ((vault:token))
+        URL: ((endpoint.url))
+  - set_pipeline: self
+    file: ci/child.yml
+    vars:
+      branch: ((branch))
+      endpoint: ((endpoint))
+`
+
+			err = ioutil.WriteFile(childPath, []byte(childConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelineConfig := `---
+resources:
+- name: ci
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: ci
+  - set_pipeline: child
+    file: ci/child.yml
+    vars:
+      branch: main
+      unused: value
+`
+
+			err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lints the child pipeline and the vars it is given", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule     string   `json:"rule"`
+					Pipeline string   `json:"pipeline"`
+					Task     string   `json:"task"`
+					Extras   []string `json:"extras"`
+					Missing  []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(3))
+			Expect(report.Findings[0].Rule).To(Equal("pipeline-vars"))
+			Expect(report.Findings[0].Pipeline).To(Equal(pipelinePath))
+			Expect(report.Findings[0].Task).To(Equal("child"))
+			Expect(report.Findings[0].Extras).To(Equal([]string{"unused"}))
+			Expect(report.Findings[0].Missing).To(Equal([]string{"endpoint"}))
+			Expect(report.Findings[1].Rule).To(Equal("task-definition"))
+			Expect(report.Findings[1].Pipeline).To(Equal(childPath))
+			Expect(report.Findings[1].Task).To(Equal("child-task"))
+			Expect(report.Findings[2].Rule).To(Equal("required-inputs"))
+			Expect(report.Findings[2].Pipeline).To(Equal(childPath))
+			Expect(report.Findings[2].Task).To(Equal("self"))
+			Expect(report.Findings[2].Missing).To(Equal([]string{"ci"}))
+		})
+
+		It("reports the child pipeline once when it is also linted on its own", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-p", childPath, "-c", configFilePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule     string `json:"rule"`
+					Pipeline string `json:"pipeline"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(3))
+			Expect(report.Findings[0].Rule).To(Equal("pipeline-vars"))
+			Expect(report.Findings[0].Pipeline).To(Equal(pipelinePath))
+			Expect(report.Findings[1].Rule).To(Equal("task-definition"))
+			Expect(report.Findings[1].Pipeline).To(Equal(childPath))
+			Expect(report.Findings[2].Rule).To(Equal("required-inputs"))
+			Expect(report.Findings[2].Pipeline).To(Equal(childPath))
+		})
+	})
+
+	Context("when vars are given to interpolate into the pipeline", func() {
+		var varsPath string
+
+		BeforeEach(func() {
+			varsPath = filepath.Join(tmpDir, "vars.yml")
+			vars := `---
+branch: main
+run:
+  path: some-command
+`
+
+			err := ioutil.WriteFile(varsPath, []byte(vars), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+  source:
+    branch: {{branch}}
+    uri: ((uri))
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - task: some-task
+    config:
+      params:
+        TOKEN:
+        TAG:
+      run: ((run))
+    params:
+      TOKEN: ((vault:token))
+      TAG: ((tag))
+`
+
+			err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("interpolates the vars and reports those that are not provided", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-l", varsPath, "-v", "uri=https://example.com", "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule    string   `json:"rule"`
+					Line    int      `json:"line"`
+					Missing []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(1))
+			Expect(report.Findings[0].Rule).To(Equal("undefined-vars"))
+			Expect(report.Findings[0].Line).To(Equal(21))
+			Expect(report.Findings[0].Missing).To(Equal([]string{"tag"}))
+		})
+
+		It("does not report vars when none are given", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Out).NotTo(gbytes.Say("undefined-vars"))
+		})
+	})
+
+	Context("when a var with a map value is interpolated into a larger string", func() {
+		var varsPath string
+
+		BeforeEach(func() {
+			varsPath = filepath.Join(tmpDir, "vars.yml")
+			vars := `---
+repo:
+  uri: https://example.com/some-repo
+`
+
+			err := ioutil.WriteFile(varsPath, []byte(vars), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+  source:
+    uri: ((repo.uri))
+    branch: release-((repo))
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+`
+
+			err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports the var instead of interpolating it", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-l", varsPath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule   string   `json:"rule"`
+					Line   int      `json:"line"`
+					Extras []string `json:"extras"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(1))
+			Expect(report.Findings[0].Rule).To(Equal("var-interpolation"))
+			Expect(report.Findings[0].Line).To(Equal(7))
+			Expect(report.Findings[0].Extras).To(Equal([]string{"repo"}))
+		})
+	})
+
+	Context("when vars are provided that the pipeline and its tasks do not use", func() {
+		var (
+			varsPath string
+			taskPath string
+		)
+
+		BeforeEach(func() {
+			someResourceDir := filepath.Join(tmpDir, "some-resource")
+			err := os.MkdirAll(someResourceDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			testpipeConfig := fmt.Sprintf(`---
+resource_map:
+  some-resource: %s`, someResourceDir)
+
+			configFilePath = filepath.Join(tmpDir, "testpipe-config.yml")
+			err = ioutil.WriteFile(configFilePath, []byte(testpipeConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			taskPath = filepath.Join(someResourceDir, "task.yml")
+			taskConfig := `---
+params:
+  IMAGE_TAG: ((image.tag))
+  # ((unused-var)) is only mentioned in a comment
+  REGION: ((region))
+run:
+  path: some-command
+`
+
+			err = ioutil.WriteFile(taskPath, []byte(taskConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			varsPath = filepath.Join(tmpDir, "vars.yml")
+			vars := `---
+"dotted.name": some-value
+image:
+  tag: latest
+unused-var: some-value
+`
+
+			err = ioutil.WriteFile(varsPath, []byte(vars), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+  source:
+    uri: (("dotted.name"))
+    private_key: ((vault:deploy-key))
+    branch: ((image.branch))
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - task: some-task
+    file: some-resource/task.yml
+    params:
+      IMAGE_TAG: ((.:tag))
+      REGION: ((region))
+`
+
+			err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports vars that are missing and vars that nothing uses", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath, "-l", varsPath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule    string   `json:"rule"`
+					File    string   `json:"file"`
+					Line    int      `json:"line"`
+					Extras  []string `json:"extras"`
+					Missing []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(4))
+			Expect(report.Findings[0].Rule).To(Equal("local-vars"))
+			Expect(report.Findings[0].Missing).To(Equal([]string{"tag"}))
+			Expect(report.Findings[1].Rule).To(Equal("undefined-vars"))
+			Expect(report.Findings[1].File).To(Equal(pipelinePath))
+			Expect(report.Findings[1].Line).To(Equal(8))
+			Expect(report.Findings[1].Missing).To(Equal([]string{"image.branch"}))
+			Expect(report.Findings[2].Rule).To(Equal("undefined-vars"))
+			Expect(report.Findings[2].File).To(Equal(pipelinePath))
+			Expect(report.Findings[2].Line).To(Equal(18))
+			Expect(report.Findings[2].Missing).To(Equal([]string{"region"}))
+			Expect(report.Findings[3].Rule).To(Equal("unused-vars"))
+			Expect(report.Findings[3].Extras).To(Equal([]string{"unused-var"}))
+		})
+
+		It("does not report vars shared with other pipelines that use them", func() {
+			otherPipelinePath := filepath.Join(tmpDir, "other-pipeline.yml")
+			err := ioutil.WriteFile(otherPipelinePath, []byte(`---
+resources:
+- name: some-resource
+  type: git
+  source:
+    uri: ((unused-var))
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+`), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-p", otherPipelinePath, "-c", configFilePath, "-l", varsPath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Out).To(gbytes.Say("undefined-vars"))
+			Expect(session.Out).NotTo(gbytes.Say("unused-vars"))
+		})
+
+		It("reports vars shared with other pipelines that none of them use once", func() {
+			otherPipelinePath := filepath.Join(tmpDir, "other-pipeline.yml")
+			err := ioutil.WriteFile(otherPipelinePath, []byte(`---
+resources:
+- name: some-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+`), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-p", otherPipelinePath, "-c", configFilePath, "-l", varsPath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule     string   `json:"rule"`
+					Pipeline string   `json:"pipeline"`
+					Extras   []string `json:"extras"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			var unused []string
+			for _, finding := range report.Findings {
+				if finding.Rule == "unused-vars" {
+					Expect(finding.Pipeline).To(Equal(pipelinePath))
+					unused = append(unused, finding.Extras...)
+				}
+			}
+			Expect(unused).To(Equal([]string{"unused-var"}))
+		})
+	})
+
+	Context("when pipelines are given as a directory or glob pattern", func() {
+		var (
+			ciDir          string
+			stagingPath    string
+			productionPath string
+		)
+
+		BeforeEach(func() {
+			ciDir = filepath.Join(tmpDir, "ci")
+
+			pipelineConfig := `---
+jobs:
+- name: some-job
+  plan:
+  - get: some-undeclared-resource
+`
+
+			files := map[string]string{
+				"staging/pipeline.yml":                      pipelineConfig,
+				"production/nested/pipeline-production.yml": pipelineConfig,
+				"tasks/task.yml":                            "---\nparams:\n  SOME_PARAM:\nrun:\n  path: some-command\n",
+				"vars.yml":                                  "---\nsome-var: some-value\n",
+				".git/pipeline.yml":                         pipelineConfig,
+			}
+
+			for name, contents := range files {
+				path := filepath.Join(ciDir, name)
+				err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = ioutil.WriteFile(path, []byte(contents), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			stagingPath = filepath.Join(ciDir, "staging", "pipeline.yml")
+			productionPath = filepath.Join(ciDir, "production", "nested", "pipeline-production.yml")
+		})
+
+		lintedPipelines := func(args ...string) []string {
+			cmd := exec.Command(cmdPath, append(args, "--format", "json")...)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Pipeline string `json:"pipeline"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			var pipelines []string
+			for _, finding := range report.Findings {
+				pipelines = append(pipelines, finding.Pipeline)
+			}
+
+			return pipelines
+		}
+
+		It("lints every pipeline in the directory, skipping tasks and other files", func() {
+			Expect(lintedPipelines("-p", ciDir)).To(Equal([]string{productionPath, stagingPath}))
+		})
+
+		It("lints every pipeline matching the pattern", func() {
+			pattern := filepath.Join(ciDir, "**", "pipeline-*.yml")
+			Expect(lintedPipelines("-p", pattern)).To(Equal([]string{productionPath}))
+		})
+
+		It("lints each pipeline once when given more than once", func() {
+			Expect(lintedPipelines("-p", stagingPath, "-p", ciDir)).To(Equal([]string{stagingPath, productionPath}))
+		})
+
+		It("reports pipelines given explicitly under the path given", func() {
+			cmd := exec.Command(cmdPath, "-p", filepath.Join("staging", "pipeline.yml"), "-p", ".", "--format", "json")
+			cmd.Dir = ciDir
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Pipeline string `json:"pipeline"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(2))
+			Expect(report.Findings[0].Pipeline).To(Equal(filepath.Join("staging", "pipeline.yml")))
+			Expect(report.Findings[1].Pipeline).To(Equal(productionPath))
+		})
+
+		It("fails when a pattern matches no pipelines", func() {
+			cmd := exec.Command(cmdPath, "-p", filepath.Join(ciDir, "**", "*.json"))
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("no pipelines found"))
+		})
+	})
+
+	Context("when many pipelines sharing a task are linted in parallel", func() {
+		var pipelinesDir string
+
+		BeforeEach(func() {
+			someResourceDir := filepath.Join(tmpDir, "some-resource")
+			err := os.MkdirAll(someResourceDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			testpipeConfig := fmt.Sprintf(`---
+resource_map:
+  some-resource: %s`, someResourceDir)
+
+			configFilePath = filepath.Join(tmpDir, "testpipe-config.yml")
+			err = ioutil.WriteFile(configFilePath, []byte(testpipeConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			taskConfig := `---
+params:
+  SOME_PARAM:
+run:
+  path: some-command
+`
+
+			err = ioutil.WriteFile(filepath.Join(someResourceDir, "task.yml"), []byte(taskConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelinesDir = filepath.Join(tmpDir, "pipelines")
+			err = os.MkdirAll(pipelinesDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			for i := 0; i < 10; i++ {
+				pipelineConfig := fmt.Sprintf(`---
+resources:
+- name: some-resource
+  type: git
+
+jobs:
+- name: some-job-%d
+  plan:
+  - get: some-resource
+  - task: some-task
+    file: some-resource/task.yml
+    params:
+      SOME_OTHER_PARAM: some-value
+`, i)
+
+				path := filepath.Join(pipelinesDir, fmt.Sprintf("pipeline-%d.yml", i))
+				err = ioutil.WriteFile(path, []byte(pipelineConfig), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("reports the same findings in the same order as when linted one at a time", func() {
+			lint := func(jobs string) []byte {
+				cmd := exec.Command(cmdPath, "-p", pipelinesDir, "-c", configFilePath, "--jobs", jobs, "--format", "json")
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(1))
+
+				return session.Out.Contents()
+			}
+
+			serial := lint("1")
+			Expect(lint("4")).To(MatchJSON(serial))
+
+			var report struct {
+				Findings []struct {
+					Job string `json:"job"`
+				} `json:"findings"`
+			}
+			err := json.Unmarshal(serial, &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(10))
+			Expect(report.Findings[0].Job).To(Equal("some-job-0"))
+			Expect(report.Findings[9].Job).To(Equal("some-job-9"))
+		})
+	})
+
+	Context("when task files are linted on their own", func() {
+		var (
+			validTaskPath   string
+			invalidTaskPath string
+		)
+
+		BeforeEach(func() {
+			validTaskPath = filepath.Join(tmpDir, "valid-task.yml")
+			validTask := `---
+platform: linux
+image_resource:
+  type: registry-image
+  source: {repository: busybox}
+inputs:
+- name: some-input
+outputs:
+- name: some-output
+run:
+  path: some-command
+`
+
+			err := ioutil.WriteFile(validTaskPath, []byte(validTask), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			invalidTaskPath = filepath.Join(tmpDir, "invalid-task.yml")
+			invalidTask := `---
+inputs:
+- name: some-input
+- name: some-input
+- name: some-other-input
+  path: shared
+outputs:
+- name: some-output
+  path: shared
+run:
+  args: [some-arg]
+some-unknown-key: some-value
+`
+
+			err = ioutil.WriteFile(invalidTaskPath, []byte(invalidTask), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("exits successfully when the task is valid", func() {
+			cmd := exec.Command(cmdPath, "task", "-f", validTaskPath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+		})
+
+		It("reports every problem with an invalid task", func() {
+			cmd := exec.Command(cmdPath, "task", "-f", invalidTaskPath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule    string   `json:"rule"`
+					File    string   `json:"file"`
+					Line    int      `json:"line"`
+					Message string   `json:"message"`
+					Extras  []string `json:"extras"`
+					Missing []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(6))
+			Expect(report.Findings[0].Missing).To(Equal([]string{"platform"}))
+			Expect(report.Findings[1].Missing).To(Equal([]string{"image_resource"}))
+			Expect(report.Findings[2].Rule).To(Equal("task-definition"))
+			Expect(report.Findings[2].Line).To(Equal(10))
+			Expect(report.Findings[2].Missing).To(Equal([]string{"run.path"}))
+			Expect(report.Findings[3].Rule).To(Equal("task-keys"))
+			Expect(report.Findings[3].File).To(Equal(invalidTaskPath))
+			Expect(report.Findings[3].Line).To(Equal(12))
+			Expect(report.Findings[3].Extras).To(Equal([]string{"some-unknown-key"}))
+			Expect(report.Findings[4].Rule).To(Equal("task-artifacts"))
+			Expect(report.Findings[4].Message).To(Equal("Task has inputs with the same name"))
+			Expect(report.Findings[4].Extras).To(Equal([]string{"some-input"}))
+			Expect(report.Findings[5].Rule).To(Equal("task-artifacts"))
+			Expect(report.Findings[5].Message).To(Equal("Task has artifacts with the same path"))
+			Expect(report.Findings[5].Extras).To(Equal([]string{"shared"}))
+		})
+
+		It("applies the rules configured in the config file", func() {
+			configFilePath = filepath.Join(tmpDir, "testpipe-config.yml")
+			err := ioutil.WriteFile(configFilePath, []byte(`---
+rules:
+  task-definition:
+    severity: warning
+  task-keys:
+    disabled: true
+  task-artifacts:
+    disabled: true
+`), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "task", "-f", invalidTaskPath, "-c", configFilePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+
+			var report struct {
+				Findings []struct {
+					Rule     string `json:"rule"`
+					Severity string `json:"severity"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(3))
+			for _, finding := range report.Findings {
+				Expect(finding.Rule).To(Equal("task-definition"))
+				Expect(finding.Severity).To(Equal("warning"))
+			}
+		})
+
+		It("reports an input and an output of the same name as sharing a path", func() {
+			taskPath := filepath.Join(tmpDir, "same-name-task.yml")
+			err := ioutil.WriteFile(taskPath, []byte(`---
+platform: linux
+image_resource:
+  type: registry-image
+  source: {repository: busybox}
+inputs:
+- name: some-repo
+- name: some-other-repo
+outputs:
+- name: some-repo
+- name: some-other-repo
+  path: some-other-repo-changed
+run:
+  path: some-command
+`), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "task", "-f", taskPath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule    string   `json:"rule"`
+					Message string   `json:"message"`
+					Extras  []string `json:"extras"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(1))
+			Expect(report.Findings[0].Rule).To(Equal("task-artifacts"))
+			Expect(report.Findings[0].Message).To(Equal("Task has artifacts with the same path"))
+			Expect(report.Findings[0].Extras).To(Equal([]string{"some-repo"}))
+		})
+	})
+
+	Context("when a command is given", func() {
+		BeforeEach(func() {
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+- name: some-image
+  type: registry-image
+
+jobs:
+- name: some-build-job
+  plan:
+  - get: some-resource
+    trigger: true
+  - task: some-task
+    file: some-resource/task.yml
+  - put: some-image
+- name: some-deploy-job
+  plan:
+  - get: some-image
+    passed: [some-build-job]
+`
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lints pipelines with lint, as when no command is given", func() {
+			cmd := exec.Command(cmdPath, "lint", "-p", pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("failed to load some-resource/task.yml; no config provided"))
+		})
+
+		It("prints the job graph with graph", func() {
+			cmd := exec.Command(cmdPath, "graph", "-p", pipelinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`digraph "%s" {`, pipelinePath))
+			Expect(session.Out).To(gbytes.Say(`"resource:some-resource" -> "job:some-build-job" \[label="some-resource" style=solid\];`))
+			Expect(session.Out).To(gbytes.Say(`"job:some-build-job" -> "resource:some-image" \[label="some-image" style=solid\];`))
+			Expect(session.Out).To(gbytes.Say(`"job:some-build-job" -> "job:some-deploy-job" \[label="some-image" style=dashed\];`))
+		})
+
+		It("refuses formats graph can't output", func() {
+			cmd := exec.Command(cmdPath, "graph", "-p", pipelinePath, "--format", "junit")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("the graph command can only output text or json, not junit"))
+			Expect(session.Out.Contents()).To(BeEmpty())
+		})
+
+		It("lists the rules with rules", func() {
+			cmd := exec.Command(cmdPath, "rules", "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+
+			var rules []struct {
+				ID          string `json:"id"`
+				Severity    string `json:"severity"`
+				Description string `json:"description"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &rules)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(rules).NotTo(BeEmpty())
+			Expect(rules[0].ID).To(Equal("params-parity"))
+			Expect(rules[0].Severity).To(Equal("error"))
+			Expect(rules[0].Description).NotTo(BeEmpty())
+		})
+
+		It("generates a config with the resources the pipeline loads files from with init", func() {
+			resourceDir := filepath.Join(tmpDir, "some-resource")
+			err := os.MkdirAll(resourceDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "init", "-p", pipelinePath)
+			cmd.Dir = tmpDir
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(string(session.Out.Contents())).To(MatchYAML(fmt.Sprintf(`---
+resource_map:
+  some-resource: %s
+`, resourceDir)))
+		})
+	})
+
+	Context("when a workspace of checked out repositories is given", func() {
+		var workspace string
+
+		BeforeEach(func() {
+			workspace = filepath.Join(tmpDir, "workspace")
+
+			someRepoDir := filepath.Join(workspace, "some-org", "some-repo")
+			err := os.MkdirAll(someRepoDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			taskConfig := `---
+platform: linux
+run:
+  path: some-command
+`
+			err = ioutil.WriteFile(filepath.Join(someRepoDir, "task.yml"), []byte(taskConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = os.MkdirAll(filepath.Join(workspace, "other-resource"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+  source:
+    uri: git@github.com:some-org/some-repo.git
+- name: other-resource
+  type: git
+  source:
+    uri: ((other-uri))
+- name: some-image
+  type: registry-image
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - get: other-resource
+  - get: some-image
+  - task: some-task
+    file: some-resource/task.yml
+`
+
+			err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("finds files in the repositories the git resources point at", func() {
+			cmd := exec.Command(cmdPath, "lint", "-p", pipelinePath, "--workspace", workspace)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+		})
+
+		It("generates a config mapping the git resources with init", func() {
+			cmd := exec.Command(cmdPath, "init", "-p", pipelinePath, "--workspace", workspace)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(string(session.Out.Contents())).To(MatchYAML(fmt.Sprintf(`---
+resource_map:
+  some-resource: %s
+  other-resource: %s
+`, filepath.Join(workspace, "some-org", "some-repo"), filepath.Join(workspace, "other-resource"))))
+		})
+
+		It("prefers the resource map given in the config", func() {
+			otherDir := filepath.Join(tmpDir, "elsewhere")
+			err := os.MkdirAll(otherDir, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			configPath := filepath.Join(tmpDir, "config.yml")
+			err = ioutil.WriteFile(configPath, []byte(fmt.Sprintf(`---
+resource_map:
+  some-resource: %s
+`, otherDir)), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "lint", "-p", pipelinePath, "-c", configPath, "--workspace", workspace)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("failed to open task at %s", filepath.Join(otherDir, "task.yml")))
+		})
+	})
+
+	Context("when rules are configured in the config file", func() {
+		BeforeEach(func() {
+			pipelineConfig := `---
+resources:
+- name: some-unused-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - task: some-task
+    config:
+      params:
+        some_param:
+      run:
+        path: some-command
+    params:
+      some_param: some-value
+      WRAPPER_FLAGS: some-flags
+  - task: some-other-task
+    config:
+      params:
+        some_other_param:
+      run:
+        path: some-command
+`
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			testpipeConfig := `---
+rules:
+  unused-resource:
+    disabled: true
+  params-parity:
+    severity: warning
+    options:
+      allowed_extra_params: [WRAPPER_FLAGS]
+`
+
+			configFilePath = filepath.Join(tmpDir, "testpipe-config.yml")
+			err = ioutil.WriteFile(configFilePath, []byte(testpipeConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports findings with the configured severity and options, exiting successfully without errors", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+
+			var report struct {
+				Findings []struct {
+					Rule     string   `json:"rule"`
+					Severity string   `json:"severity"`
+					Task     string   `json:"task"`
+					Extras   []string `json:"extras"`
+					Missing  []string `json:"missing"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(1))
+			Expect(report.Findings[0].Rule).To(Equal("params-parity"))
+			Expect(report.Findings[0].Severity).To(Equal("warning"))
+			Expect(report.Findings[0].Task).To(Equal("some-other-task"))
+			Expect(report.Findings[0].Extras).To(BeEmpty())
+			Expect(report.Findings[0].Missing).To(Equal([]string{"some_other_param"}))
+		})
+
+		It("labels findings that aren't errors in text output", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Err).To(gbytes.Say("warning: Params do not have parity"))
+		})
+
+		It("exits with error when a configured rule doesn't exist", func() {
+			err := ioutil.WriteFile(configFilePath, []byte("rules: {no-such-rule: {disabled: true}}"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Err).To(gbytes.Say("unknown rule in config: no-such-rule"))
+		})
+	})
+
+	Context("when findings are suppressed by comments", func() {
+		BeforeEach(func() {
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+
+jobs:
+# testpipe:ignore passed-constraints
+- name: some-job
+  plan:
+  - get: some-resource
+    passed: [some-missing-job]
+  # testpipe:ignore params-parity
+  - task: some-task
+    config:
+      params:
+        some_param:
+      run:
+        path: some-command
+    params:
+      some_param: some-value
+      WRAPPER_FLAGS: some-flags
+  - task: some-other-task # testpipe:ignore step-keys, task-keys
+    config:
+      run:
+        path: some-command
+    some-invalid-key: some-value
+- name: some-other-job
+  plan:
+  - get: some-resource # testpipe:ignore passed-constraints
+    passed: [some-missing-job]
+`
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports only the suppressions that no longer match anything", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+
+			var report struct {
+				Findings []struct {
+					Rule     string   `json:"rule"`
+					Severity string   `json:"severity"`
+					Line     int      `json:"line"`
+					Extras   []string `json:"extras"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(1))
+			Expect(report.Findings[0].Rule).To(Equal("unused-suppressions"))
+			Expect(report.Findings[0].Severity).To(Equal("warning"))
+			Expect(report.Findings[0].Line).To(Equal(22))
+			Expect(report.Findings[0].Extras).To(Equal([]string{"task-keys"}))
+		})
+	})
+
+	Context("when a baseline of findings is used", func() {
+		var baselinePath string
+
+		BeforeEach(func() {
+			baselinePath = filepath.Join(tmpDir, "baseline.json")
+
+			pipelineConfig := `---
+resources:
+- name: some-resource
+  type: git
+- name: some-unused-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+  - task: some-task
+    config:
+      params:
+        some_param:
+      run:
+        path: some-command
+`
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--write-baseline", baselinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+		})
+
+		It("records the current findings by pipeline, job, task and rule", func() {
+			var b struct {
+				Findings []struct {
+					Job   string `json:"job"`
+					Task  string `json:"task"`
+					Rule  string `json:"rule"`
+					Count int    `json:"count"`
+				} `json:"findings"`
+			}
+			bs, err := ioutil.ReadFile(baselinePath)
+			Expect(err).NotTo(HaveOccurred())
+			err = json.Unmarshal(bs, &b)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(b.Findings).To(HaveLen(2))
+			Expect(b.Findings[0].Rule).To(Equal("unused-resource"))
+			Expect(b.Findings[0].Task).To(Equal("some-unused-resource"))
+			Expect(b.Findings[1].Rule).To(Equal("params-parity"))
+			Expect(b.Findings[1].Job).To(Equal("some-job"))
+			Expect(b.Findings[1].Task).To(Equal("some-task"))
+			Expect(b.Findings[1].Count).To(Equal(1))
+		})
+
+		It("fails only on findings that are not in the baseline, however the pipeline has moved", func() {
+			pipelineConfig := `---
+resources:
+- name: some-new-resource
+  type: git
+- name: some-resource
+  type: git
+- name: some-unused-resource
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: some-resource
+
+  - task: some-task
+    config:
+      params:
+        some_param:
+      run:
+        path: some-command
+`
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--baseline", baselinePath, "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+
+			var report struct {
+				Findings []struct {
+					Rule string `json:"rule"`
+					Task string `json:"task"`
+				} `json:"findings"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Findings).To(HaveLen(1))
+			Expect(report.Findings[0].Rule).To(Equal("unused-resource"))
+			Expect(report.Findings[0].Task).To(Equal("some-new-resource"))
+		})
+	})
+
+	Context("when params that do not have parity are fixed", func() {
+		var pipelineConfig string
+
+		BeforeEach(func() {
+			pipelineConfig = `---
+jobs:
+- name: some-job
+  plan:
+  # the task to fix
+  - task: some-task
+    config:
+      params:
+        some_param: some-default
+        some_defaulted_param: "yes"
+        some_other_param:
+      run:
+        path: some-command
+    params:
+      some_extra_param: some-value # to be removed
+      some_param: some-value
+  - task: some-fine-task
+    config:
+      run:
+        path: some-command
+`
+
+			err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rewrites the pipeline, keeping its comments and formatting", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--fix")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+
+			fixed, err := ioutil.ReadFile(pipelinePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(fixed)).To(Equal(`---
+jobs:
+- name: some-job
+  plan:
+  # the task to fix
+  - task: some-task
+    config:
+      params:
+        some_param: some-default
+        some_defaulted_param: "yes"
+        some_other_param:
+      run:
+        path: some-command
+    params:
+      some_param: some-value
+      some_defaulted_param: "yes"
+      some_other_param: ((TODO))
+  - task: some-fine-task
+    config:
+      run:
+        path: some-command
+`))
+		})
+
+		It("prints a unified diff without changing the pipeline with --dry-run", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--fix", "--dry-run")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(string(session.Out.Contents())).To(Equal(fmt.Sprintf(`--- %s
++++ %s
+@@ -12,8 +12,9 @@
+       run:
+         path: some-command
+     params:
+-      some_extra_param: some-value # to be removed
+       some_param: some-value
++      some_defaulted_param: "yes"
++      some_other_param: ((TODO))
+   - task: some-fine-task
+     config:
+       run:
+`, pipelinePath, pipelinePath)))
+
+			unchanged, err := ioutil.ReadFile(pipelinePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(unchanged)).To(Equal(pipelineConfig))
+		})
+
+		It("refuses to print a diff to stdout along with a report in another format", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--dry-run", "--format", "json")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Out.Contents()).To(BeEmpty())
+			Expect(session.Err).To(gbytes.Say("--format json needs --output"))
+		})
+
+		It("fixes the findings before writing a baseline of those that remain", func() {
+			baselinePath := filepath.Join(tmpDir, "baseline.json")
+
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "--fix", "--write-baseline", baselinePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+
+			fixed, err := ioutil.ReadFile(pipelinePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(fixed)).NotTo(ContainSubstring("some_extra_param"))
+
+			var b struct {
+				Findings []interface{} `json:"findings"`
+			}
+			bs, err := ioutil.ReadFile(baselinePath)
+			Expect(err).NotTo(HaveOccurred())
+			err = json.Unmarshal(bs, &b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b.Findings).To(BeEmpty())
+		})
+
+		Context("when the params are given by a var with a map value", func() {
+			var varsPath string
+
+			BeforeEach(func() {
+				pipelineConfig = `---
+jobs:
+- name: some-job
+  plan:
+  - task: some-task
+    config:
+      params:
+        some_param:
+      run:
+        path: some-command
+    params: ((common))
+`
+
+				err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+
+				varsPath = filepath.Join(tmpDir, "vars.yml")
+				err = ioutil.WriteFile(varsPath, []byte("---\ncommon:\n  some_param: 1\n  some_extra_param: 2\n"), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("leaves them for fixing by hand", func() {
+				cmd := exec.Command(cmdPath, "-p", pipelinePath, "-l", varsPath, "--fix")
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("some_extra_param"))
+
+				unchanged, err := ioutil.ReadFile(pipelinePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(unchanged)).To(Equal(pipelineConfig))
+			})
+		})
+
+		Context("when the params are shared through an alias", func() {
+			BeforeEach(func() {
+				pipelineConfig = `---
+jobs:
+- name: some-job
+  plan:
+  - task: some-task
+    config:
+      params:
+        some_param:
+      run:
+        path: some-command
+    params: &common
+      some_extra_param: some-value
+  - task: some-other-task
+    config:
+      params:
+        some_param:
+      run:
+        path: some-command
+    params: *common
+`
+
+				err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("leaves them for fixing by hand", func() {
+				cmd := exec.Command(cmdPath, "-p", pipelinePath, "--fix")
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(1))
+
+				unchanged, err := ioutil.ReadFile(pipelinePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(unchanged)).To(Equal(pipelineConfig))
+			})
+		})
+	})
+
+	Context("when a later get renames a resource a task was loaded from", func() {
+		BeforeEach(func() {
+			ciDir := filepath.Join(tmpDir, "ci")
+			otherDir := filepath.Join(tmpDir, "other")
+			for _, dir := range []string{ciDir, otherDir} {
+				err := os.MkdirAll(dir, os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			testpipeConfig := fmt.Sprintf(`---
+resource_map:
+  ci: %s
+  other: %s`, ciDir, otherDir)
+
+			configFilePath = filepath.Join(tmpDir, "testpipe-config.yml")
+			err := ioutil.WriteFile(configFilePath, []byte(testpipeConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = ioutil.WriteFile(filepath.Join(ciDir, "task.yml"), []byte("---\nrun:\n  path: some-command\n"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = ioutil.WriteFile(filepath.Join(otherDir, "task.yml"), []byte("---\nrun:\n  path: some-command\nsome-unknown-key: some-value\n"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			pipelineConfig := `---
+resources:
+- name: ci
+  type: git
+- name: other
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: ci
+  - task: some-task
+    file: ci/task.yml
+  - get: ci
+    resource: other
+`
+
+			err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lints the task as it was when the task ran", func() {
+			cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+		})
+
+		Context("when the get runs in parallel with the task", func() {
+			BeforeEach(func() {
+				pipelineConfig := `---
+resources:
+- name: ci
+  type: git
+- name: other
+  type: git
+
+jobs:
+- name: some-job
+  plan:
+  - get: ci
+  - in_parallel:
+    - task: some-task
+      file: ci/task.yml
+    - get: ci
+      resource: other
+`
+
+				err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("lints the task with the resource it was loaded from", func() {
+				cmd := exec.Command(cmdPath, "-p", pipelinePath, "-c", configFilePath)
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(0))
+			})
+		})
+	})
 })
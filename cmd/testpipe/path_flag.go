@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pipelineKeyRegexp matches the top-level keys only found in pipelines. The
+// file is matched as text so that pipelines with {{placeholders}}, which
+// aren't valid YAML, are still found.
+var pipelineKeyRegexp = regexp.MustCompile(`(?m)^(jobs|resources|resource_types|groups|var_sources|display)\s*:`)
+
+// taskKeyRegexp matches the top-level keys that make a file a task.
+var taskKeyRegexp = regexp.MustCompile(`(?m)^(run|platform)\s*:`)
+
+// PathFlag is a flag for passing pipelines as a file, a directory, or a glob
+// pattern in which ** matches any number of directories. Directories and
+// patterns are searched for pipelines, skipping task files and other YAML.
+type PathFlag struct {
+	paths []string
+}
+
+// UnmarshalFlag implements go-flag's Unmarshaler interface
+func (f *PathFlag) UnmarshalFlag(value string) error {
+	var paths []string
+
+	stat, err := os.Stat(value)
+	switch {
+	case err == nil && !stat.IsDir():
+		// Pipelines given explicitly are reported under the path given.
+		f.paths = append(f.paths, value)
+		return nil
+
+	case err == nil:
+		paths, err = findPipelines(value, func(path string) bool {
+			ext := filepath.Ext(path)
+			return ext == ".yml" || ext == ".yaml"
+		})
+		if err != nil {
+			return err
+		}
+
+	case hasMeta(value):
+		pattern := strings.Split(filepath.ToSlash(filepath.Clean(value)), "/")
+		for _, segment := range pattern {
+			if _, err := filepath.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid pattern '%s': %s", value, err)
+			}
+		}
+
+		paths, err = findPipelines(globRoot(pattern), func(path string) bool {
+			return matchSegments(pattern, strings.Split(filepath.ToSlash(path), "/"))
+		})
+		if err != nil {
+			return err
+		}
+
+	default:
+		return err
+	}
+
+	if len(paths) == 0 {
+		return fmt.Errorf("no pipelines found in '%s'", value)
+	}
+
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		f.paths = append(f.paths, abs)
+	}
+
+	return nil
+}
+
+// Paths are the paths to the pipelines
+func (f PathFlag) Paths() []string {
+	return f.paths
+}
+
+// findPipelines walks root for files that match and look like pipelines,
+// skipping hidden directories.
+func findPipelines(root string, match func(path string) bool) ([]string, error) {
+	var paths []string
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if match(path) && isPipeline(path) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+
+	return paths, err
+}
+
+// isPipeline reports whether a file has the shape of a pipeline rather than
+// of a task or any other YAML.
+func isPipeline(path string) bool {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	return pipelineKeyRegexp.Match(bs) && !taskKeyRegexp.Match(bs)
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// globRoot returns the directory holding everything a pattern may match,
+// which is made of the segments before the first with a wildcard.
+func globRoot(pattern []string) string {
+	var static []string
+	for _, segment := range pattern {
+		if hasMeta(segment) {
+			break
+		}
+		static = append(static, segment)
+	}
+
+	switch {
+	case len(static) == 0:
+		return "."
+	case len(static) == 1 && static[0] == "":
+		return "/"
+	}
+
+	return filepath.FromSlash(strings.Join(static, "/"))
+}
+
+// matchSegments matches the segments of a path against those of a pattern,
+// where a ** segment matches any number of segments.
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	if ok, _ := filepath.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/krishicks/testpipe"
+)
+
+// rulesCommand lists the rules findings are reported under.
+type rulesCommand struct {
+	opts *opts
+}
+
+type ruleDescription struct {
+	ID          string            `json:"id"`
+	Severity    testpipe.Severity `json:"severity"`
+	Description string            `json:"description"`
+}
+
+// Execute implements go-flag's Commander interface
+func (c *rulesCommand) Execute(args []string) error {
+	if c.opts.Format != "text" && c.opts.Format != "json" {
+		return fmt.Errorf("the rules command can only output text or json, not %s", c.opts.Format)
+	}
+
+	var rules []ruleDescription
+	for _, rule := range testpipe.Rules() {
+		rules = append(rules, ruleDescription{
+			ID:          rule.ID(),
+			Severity:    rule.DefaultSeverity(),
+			Description: rule.Description(),
+		})
+	}
+
+	out := c.opts.output(os.Stdout)
+
+	var err error
+	if c.opts.Format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(rules)
+	} else {
+		tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		for _, rule := range rules {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", rule.ID, rule.Severity, rule.Description)
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		log.Fatalf("Failed writing output: %s", err)
+	}
+
+	return out.Close()
+}
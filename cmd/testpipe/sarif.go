@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/krishicks/testpipe"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func writeSARIF(w io.Writer, results []result) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "testpipe",
+				InformationURI: "https://github.com/krishicks/testpipe",
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+
+	seenRules := map[string]bool{}
+	for _, r := range results {
+		if r.err != nil {
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    "pipeline",
+				Level:     "error",
+				Message:   sarifMessage{Text: r.err.Error()},
+				Locations: []sarifLocation{sarifLocationFor(testpipe.Position{File: r.path})},
+			})
+			continue
+		}
+
+		for _, finding := range r.findings {
+			if !seenRules[finding.Rule] {
+				seenRules[finding.Rule] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: finding.Rule})
+			}
+
+			pos := finding.Position
+			if pos.File == "" {
+				pos.File = finding.PipelinePath
+			}
+
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    finding.Rule,
+				Level:     sarifLevel(finding.Severity),
+				Message:   sarifMessage{Text: sarifText(finding)},
+				Locations: []sarifLocation{sarifLocationFor(pos)},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifLevel returns the SARIF level of a finding's severity.
+func sarifLevel(severity testpipe.Severity) string {
+	switch severity {
+	case testpipe.SeverityWarning:
+		return "warning"
+	case testpipe.SeverityInfo:
+		return "note"
+	}
+
+	return "error"
+}
+
+func sarifText(finding testpipe.Finding) string {
+	text := fmt.Sprintf("%s/%s: %s", finding.JobName, finding.TaskName, finding.Detail)
+	if len(finding.Extras) > 0 {
+		text += fmt.Sprintf("; extra %s: %s", finding.Kind, strings.Join(finding.Extras, ", "))
+	}
+	if len(finding.Missing) > 0 {
+		text += fmt.Sprintf("; missing %s: %s", finding.Kind, strings.Join(finding.Missing, ", "))
+	}
+
+	return text
+}
+
+// sarifLocationFor makes paths under the working directory relative so that
+// code scanning tools can match them to files in the repository, and others
+// absolute file URIs.
+func sarifLocationFor(pos testpipe.Position) sarifLocation {
+	uri := filepath.ToSlash(pos.File)
+	if abs, err := filepath.Abs(pos.File); err == nil {
+		uri = "file://" + filepath.ToSlash(abs)
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				uri = filepath.ToSlash(rel)
+			}
+		}
+	}
+
+	location := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: uri},
+		},
+	}
+
+	if pos.Line > 0 {
+		location.PhysicalLocation.Region = &sarifRegion{
+			StartLine:   pos.Line,
+			StartColumn: pos.Column,
+		}
+	}
+
+	return location
+}
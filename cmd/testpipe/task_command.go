@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/krishicks/testpipe"
+)
+
+// taskCommand lints task files on their own, without a pipeline.
+type taskCommand struct {
+	opts *opts
+
+	Files      []FileFlag `long:"file" short:"f" required:"true" value-name:"PATH" description:"Path to task file"`
+	ConfigPath FileFlag   `long:"config" short:"c" value-name:"PATH" description:"Path to config, of which only rules apply to task files"`
+}
+
+// Execute implements go-flag's Commander interface
+func (c *taskCommand) Execute(args []string) error {
+	config := readConfig(c.ConfigPath)
+
+	var results []result
+	for _, file := range c.Files {
+		findings, err := testpipe.LintTask(file.Path(), config)
+
+		results = append(results, result{
+			path: file.Path(),
+			subjects: []testpipe.Subject{{
+				PipelinePath: file.Path(),
+				TaskName:     filepath.Base(file.Path()),
+			}},
+			findings: findings,
+			err:      err,
+		})
+	}
+
+	report(*c.opts, results)
+
+	return nil
+}
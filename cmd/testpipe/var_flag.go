@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VarFlag is a flag for passing a var as name=value, as with fly's -v.
+type VarFlag struct {
+	Name  string
+	Value string
+}
+
+// UnmarshalFlag implements go-flag's Unmarshaler interface
+func (f *VarFlag) UnmarshalFlag(value string) error {
+	vs := strings.SplitN(value, "=", 2)
+	if len(vs) != 2 || vs[0] == "" {
+		return fmt.Errorf("invalid var '%s'; expected name=value", value)
+	}
+
+	f.Name = vs[0]
+	f.Value = vs[1]
+
+	return nil
+}
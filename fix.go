@@ -0,0 +1,272 @@
+package testpipe
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// paramsFix is what it takes to give a task step the params its task
+// declares.
+type paramsFix struct {
+	step     *yaml.Node
+	extras   []string
+	missing  []string
+	defaults map[string]string
+
+	// interpolated are the nodes of the pipeline that came from vars rather
+	// than the file, which can't be edited.
+	interpolated map[*yaml.Node]bool
+}
+
+// lineEdit replaces lines start through end, which are 1-based, with lines.
+// An end before start inserts lines ahead of start.
+type lineEdit struct {
+	start int
+	end   int
+	lines []string
+}
+
+// Fix returns the contents of the pipeline at path with the findings for it
+// that can be fixed automatically fixed, keeping its comments and formatting.
+// Params-parity findings are fixed by removing extra params from the task
+// step and adding missing ones with the task's default value, or ((TODO))
+// when it has none. Params given in flow style, through merge keys, by
+// aliases or by vars are left alone.
+func Fix(path string, findings []Finding) ([]byte, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines are edited with their line endings, so a file without a final
+	// newline is given one while it is edited.
+	content := string(bs)
+	unterminated := content != "" && !strings.HasSuffix(content, "\n")
+	if unterminated {
+		content += "\n"
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	lines = lines[:len(lines)-1]
+
+	var edits []lineEdit
+	fixed := map[Position]bool{}
+	for _, f := range findings {
+		if f.fix == nil || pipelineKey(f.PipelinePath) != pipelineKey(path) {
+			continue
+		}
+
+		// A step is found more than once when it is an alias of another.
+		stepPos := nodePosition(path, f.fix.step)
+		if fixed[stepPos] {
+			continue
+		}
+
+		if edit, ok := f.fix.edit(lines); ok {
+			fixed[stepPos] = true
+			edits = append(edits, edit)
+		}
+	}
+
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+
+	for _, edit := range edits {
+		rest := append(append([]string{}, edit.lines...), lines[edit.end:]...)
+		lines = append(lines[:edit.start-1], rest...)
+	}
+
+	content = strings.Join(lines, "")
+	if unterminated {
+		content = strings.TrimSuffix(content, "\n")
+	}
+
+	return []byte(content), nil
+}
+
+// edit returns the edit that rewrites the params of the step, or false when
+// they can't be rewritten without changing more than the step.
+func (fix *paramsFix) edit(lines []string) (lineEdit, bool) {
+	if fix.step.Style&yaml.FlowStyle != 0 || fix.interpolated[fix.step] {
+		return lineEdit{}, false
+	}
+
+	keyNode, valueNode := directEntry(fix.step, "params")
+
+	if keyNode == nil {
+		lastKey := lastKey(fix.step)
+		if lastKey == nil {
+			return lineEdit{}, false
+		}
+
+		end := entryEnd(lines, lastKey)
+		indent := strings.Repeat(" ", lastKey.Column-1)
+
+		newLines := []string{indent + "params:\n"}
+		newLines = append(newLines, fix.paramLines(indent+"  ")...)
+
+		return lineEdit{start: end + 1, end: end, lines: newLines}, true
+	}
+
+	// Params given by an alias, or anchored for aliases elsewhere, are shared
+	// with other steps, and those given by a var aren't in the file.
+	if valueNode.Kind == yaml.AliasNode || valueNode.Anchor != "" || fix.interpolated[valueNode] {
+		return lineEdit{}, false
+	}
+
+	start := keyNode.Line
+	end := entryEnd(lines, keyNode)
+	indent := strings.Repeat(" ", keyNode.Column-1)
+
+	switch {
+	case valueNode.Kind == yaml.ScalarNode && valueNode.Tag == "!!null" && valueNode.Value == "":
+		// A bare params: key is kept along with any comment on it.
+		newLines := append([]string{lines[start-1]}, fix.paramLines(indent+"  ")...)
+		return lineEdit{start: start, end: start, lines: newLines}, true
+
+	case valueNode.Kind == yaml.ScalarNode && valueNode.Tag == "!!null",
+		valueNode.Kind == yaml.MappingNode && len(valueNode.Content) == 0:
+		newLines := append([]string{indent + "params:\n"}, fix.paramLines(indent+"  ")...)
+		return lineEdit{start: start, end: end, lines: newLines}, true
+
+	case valueNode.Kind != yaml.MappingNode, valueNode.Style&yaml.FlowStyle != 0:
+		return lineEdit{}, false
+	}
+
+	var entryIndent string
+	remaining := 0
+	removed := map[int]bool{}
+	for i := 0; i+1 < len(valueNode.Content); i += 2 {
+		param := valueNode.Content[i]
+		if param.Value == "<<" {
+			return lineEdit{}, false
+		}
+
+		entryIndent = strings.Repeat(" ", param.Column-1)
+
+		if !contains(fix.extras, param.Value) {
+			remaining++
+			continue
+		}
+
+		for l := entryStart(lines, param); l <= entryEnd(lines, param); l++ {
+			removed[l] = true
+		}
+	}
+
+	added := fix.paramLines(entryIndent)
+
+	// Params that are all removed, with none to add, are removed along with
+	// their key.
+	if remaining == 0 && len(added) == 0 {
+		return lineEdit{start: start, end: end}, true
+	}
+
+	var newLines []string
+	for l := start; l <= end; l++ {
+		if !removed[l] {
+			newLines = append(newLines, lines[l-1])
+		}
+	}
+
+	return lineEdit{start: start, end: end, lines: append(newLines, added...)}, true
+}
+
+// paramLines returns the lines adding the missing params.
+func (fix *paramsFix) paramLines(indent string) []string {
+	var lines []string
+	for _, name := range fix.missing {
+		value := fix.defaults[name]
+		if value == "" {
+			value = "((TODO))"
+		} else {
+			value = yamlScalar(value)
+		}
+
+		lines = append(lines, indent+yamlScalar(name)+": "+value+"\n")
+	}
+
+	return lines
+}
+
+// yamlScalar renders a string as a YAML scalar on a single line, quoting it
+// only when it has to be.
+func yamlScalar(value string) string {
+	bs, err := yaml.Marshal(value)
+	if err == nil {
+		if s := strings.TrimSuffix(string(bs), "\n"); !strings.Contains(s, "\n") {
+			return s
+		}
+	}
+
+	// JSON strings are also YAML double-quoted scalars.
+	bs, _ = json.Marshal(value)
+	return string(bs)
+}
+
+// directEntry is mappingEntry without looking through merge keys or aliases,
+// for the keys of a mapping that can be edited without editing others.
+func directEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+
+	return nil, nil
+}
+
+// lastKey returns the key of a mapping that comes last in its file.
+func lastKey(node *yaml.Node) *yaml.Node {
+	var last *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if last == nil || node.Content[i].Line > last.Line {
+			last = node.Content[i]
+		}
+	}
+
+	return last
+}
+
+// entryStart returns the first line of a block mapping entry, including the
+// comment lines directly above its key.
+func entryStart(lines []string, key *yaml.Node) int {
+	start := key.Line
+	for start > 1 && strings.HasPrefix(strings.TrimSpace(lines[start-2]), "#") {
+		start--
+	}
+
+	return start
+}
+
+// entryEnd returns the last line of a block mapping entry: the last of the
+// lines after its key that are indented further than it, or are items of a
+// sequence indented as far. Blank and comment lines after the entry belong
+// to what follows it.
+func entryEnd(lines []string, key *yaml.Node) int {
+	indent := key.Column - 1
+
+	end := key.Line
+	for l := key.Line + 1; l <= len(lines); l++ {
+		text := strings.TrimRight(lines[l-1], "\r\n")
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		lineIndent := len(text) - len(strings.TrimLeft(text, " "))
+		if lineIndent > indent || lineIndent == indent && (trimmed == "-" || strings.HasPrefix(trimmed, "- ")) {
+			end = l
+			continue
+		}
+
+		break
+	}
+
+	return end
+}
@@ -0,0 +1,77 @@
+package testpipe
+
+import "github.com/concourse/atc"
+
+// Graph is how resources flow between the jobs of a pipeline.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a job or a resource.
+type GraphNode struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// GraphEdge is a resource passed from one node to another: from a resource
+// to a job that gets it, from a job to a resource it puts, or from a job to
+// a job that gets a resource that has passed through it.
+type GraphEdge struct {
+	From     GraphNode `json:"from"`
+	To       GraphNode `json:"to"`
+	Resource string    `json:"resource"`
+	Trigger  bool      `json:"trigger"`
+}
+
+func buildGraph(config atc.Config, jobSteps map[string][]step) Graph {
+	graph := Graph{
+		Nodes: []GraphNode{},
+		Edges: []GraphEdge{},
+	}
+
+	for _, resource := range config.Resources {
+		graph.Nodes = append(graph.Nodes, GraphNode{Kind: "resource", Name: resource.Name})
+	}
+
+	for _, job := range config.Jobs {
+		jobNode := GraphNode{Kind: "job", Name: job.Name}
+		graph.Nodes = append(graph.Nodes, jobNode)
+
+		for _, s := range jobSteps[job.Name] {
+			switch {
+			case s.config.Get != "":
+				name, _ := resourceName(s.config.PlanConfig)
+
+				if len(s.config.Passed) == 0 {
+					graph.Edges = append(graph.Edges, GraphEdge{
+						From:     GraphNode{Kind: "resource", Name: name},
+						To:       jobNode,
+						Resource: name,
+						Trigger:  s.config.Trigger,
+					})
+					continue
+				}
+
+				for _, passed := range s.config.Passed {
+					graph.Edges = append(graph.Edges, GraphEdge{
+						From:     GraphNode{Kind: "job", Name: passed},
+						To:       jobNode,
+						Resource: name,
+						Trigger:  s.config.Trigger,
+					})
+				}
+
+			case s.config.Put != "":
+				name, _ := resourceName(s.config.PlanConfig)
+				graph.Edges = append(graph.Edges, GraphEdge{
+					From:     jobNode,
+					To:       GraphNode{Kind: "resource", Name: name},
+					Resource: name,
+				})
+			}
+		}
+	}
+
+	return graph
+}
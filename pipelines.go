@@ -0,0 +1,165 @@
+package testpipe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// pipelineKey identifies a pipeline file however its path was written.
+func pipelineKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(path)
+}
+
+// lintChildPipeline lints the pipeline set by a set_pipeline step when its
+// file can be found through the resource map. Each pipeline is linted once,
+// however many steps set it. An error is returned when the pipeline cannot
+// be read.
+func (t *TestPipe) lintChildPipeline(
+	tasks *TaskCache,
+	resourceMap map[string]string,
+	s step,
+	linted map[string]bool,
+) ([]Finding, error) {
+	path, ok := resourcePath(resourceMap, s.config.TaskConfigPath)
+	if !ok || linted[pipelineKey(path)] {
+		return nil, nil
+	}
+
+	// Files that don't exist are reported by testPresenceOfFiles.
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+
+	// The child's vars come from the step, whose parity with the pipeline
+	// is checked by the pipeline-vars rule, rather than from the vars given
+	// to testpipe.
+	childConfig := t.config
+	childConfig.Vars = nil
+	childConfig.SharedVars = nil
+
+	child := &TestPipe{path: path, config: childConfig, tasks: tasks}
+	findings, err := child.run(linted)
+	if err != nil {
+		return nil, err
+	}
+
+	t.subjects = append(t.subjects, child.subjects...)
+
+	return findings, nil
+}
+
+// testParityOfPipelineVars reports vars a child pipeline refers to that its
+// set_pipeline step doesn't provide, and vars given under vars: that the
+// pipeline doesn't use. Vars in var_files may be shared between pipelines,
+// so only missing vars are reported for them, and only when every file can
+// be read.
+func testParityOfPipelineVars(
+	pipeline []byte,
+	resourceMap map[string]string,
+	s step,
+	jobName string,
+	pipelinePath string,
+) []Finding {
+	used := map[string]bool{}
+	for _, name := range pipelineVars(pipeline) {
+		used[name] = true
+	}
+
+	provided := map[string]bool{}
+	for name := range s.config.Vars {
+		provided[name] = true
+	}
+
+	complete := true
+	for _, varFile := range s.config.VarFiles {
+		vars, ok := loadVarFile(resourceMap, varFile)
+		if !ok {
+			complete = false
+			continue
+		}
+
+		for name := range vars {
+			provided[name] = true
+		}
+	}
+
+	var extras, missing []string
+
+	for name := range s.config.Vars {
+		if !used[name] {
+			extras = append(extras, name)
+		}
+	}
+
+	if complete {
+		for name := range used {
+			if !provided[name] {
+				missing = append(missing, name)
+			}
+		}
+	}
+
+	if len(extras) == 0 && len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(extras)
+	sort.Strings(missing)
+
+	return []Finding{{
+		Rule:         RulePipelineVars,
+		Kind:         "vars",
+		PipelinePath: pipelinePath,
+		JobName:      jobName,
+		TaskName:     s.config.Name(),
+		Position:     s.position(pipelinePath, "vars"),
+		Detail:       "Vars do not have parity",
+		Extras:       extras,
+		Missing:      missing,
+	}}
+}
+
+// pipelineVars returns the names of the vars a pipeline refers to, leaving
+// out local vars and those fetched from a named var source.
+func pipelineVars(pipeline []byte) []string {
+	var names []string
+	for _, token := range tokenizeVars(string(pipeline)) {
+		if token.ref.source == "" {
+			names = appendUnique(names, token.ref.path)
+		}
+	}
+
+	return names
+}
+
+// loadVarFile reads a var file given to a set_pipeline step through the
+// resource map.
+func loadVarFile(
+	resourceMap map[string]string,
+	varFile string,
+) (map[string]interface{}, bool) {
+	path, ok := resourcePath(resourceMap, varFile)
+	if !ok {
+		return nil, false
+	}
+
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var vars map[string]interface{}
+	if err := yaml.Unmarshal(bs, &vars); err != nil {
+		return nil, false
+	}
+
+	return vars, true
+}
@@ -0,0 +1,241 @@
+package testpipe
+
+import (
+	"github.com/concourse/atc"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// planConfig extends atc.PlanConfig with the steps and modifiers added to
+// Concourse since the version of atc this package is built against.
+type planConfig struct {
+	atc.PlanConfig `yaml:",inline"`
+
+	// InParallel is kept as a node since it is either a list of steps or a
+	// map of them along with limit and fail_fast. It can't be a *yaml.Node,
+	// which yaml.v3 won't decode a list into.
+	InParallel yaml.Node `yaml:"in_parallel,omitempty"`
+
+	SetPipeline string                 `yaml:"set_pipeline,omitempty"`
+	Vars        map[string]interface{} `yaml:"vars,omitempty"`
+	VarFiles    []string               `yaml:"var_files,omitempty"`
+	Team        string                 `yaml:"team,omitempty"`
+
+	LoadVar string `yaml:"load_var,omitempty"`
+	Format  string `yaml:"format,omitempty"`
+	Reveal  bool   `yaml:"reveal,omitempty"`
+
+	Across []acrossVar `yaml:"across,omitempty"`
+}
+
+// acrossVar is one of the vars a step is run across.
+type acrossVar struct {
+	Var         string        `yaml:"var"`
+	Values      []interface{} `yaml:"values"`
+	MaxInFlight interface{}   `yaml:"max_in_flight,omitempty"`
+}
+
+// Name returns the name of the step as shown in the Concourse UI.
+func (config planConfig) Name() string {
+	switch {
+	case config.SetPipeline != "":
+		return config.SetPipeline
+	case config.LoadVar != "":
+		return config.LoadVar
+	}
+
+	return config.PlanConfig.Name()
+}
+
+// step is a plan step along with the YAML node it was decoded from.
+type step struct {
+	config planConfig
+	node   *yaml.Node
+}
+
+// position returns the position of key within the step, or of the step
+// itself when the key is absent.
+func (s step) position(file string, key string) Position {
+	return keyPosition(file, s.node, key)
+}
+
+// scope is what a step can see of the artifacts and local vars produced
+// before it runs.
+type scope struct {
+	// resources are sure to be present.
+	resources []string
+
+	// uncertain resources were produced by the plan but may not be present,
+	// e.g. in a job's on_failure hook, where the plan may have stopped early.
+	uncertain []string
+
+	// siblings are produced by steps running in parallel, which are never
+	// visible to each other.
+	siblings []string
+
+	// vars are the local vars set by load_var steps and across.
+	vars []string
+}
+
+// with returns a copy of the scope in which resources are also present.
+func (sc scope) with(resources ...string) scope {
+	sc.resources = withResources(sc.resources, resources...)
+	return sc
+}
+
+// visitFunc is called for every get, put, task, set_pipeline and load_var
+// with the scope it runs in, and returns the resources the step produces.
+type visitFunc func(s step, sc scope) []string
+
+// planWalker visits the steps of a plan in the order they run.
+type planWalker struct {
+	visit visitFunc
+
+	// outputs returns the resources a step produces without linting it; it
+	// is used to find out what parallel siblings produce.
+	outputs func(s step) []string
+
+	// invalid is called for steps that cannot be decoded, which are not
+	// walked any further.
+	invalid func(node *yaml.Node, err error)
+}
+
+// walkPlan visits a sequence of steps and returns the scope once they have
+// all completed.
+func (w planWalker) walkPlan(items []*yaml.Node, sc scope) scope {
+	for _, item := range items {
+		sc = w.walkStep(item, sc)
+	}
+
+	return sc
+}
+
+// walkStep visits a single step and its hooks. A step's on_failure, on_error
+// and on_abort hooks see the step's outputs, but their own outputs are never
+// seen by later steps since the build fails regardless. What a try step or a
+// step guarded by ensure produces is uncertain for the steps that run whether
+// or not it succeeded.
+func (w planWalker) walkStep(node *yaml.Node, sc scope) scope {
+	var config planConfig
+	err := decodeNode(node, &config)
+	if err != nil {
+		if w.invalid != nil {
+			w.invalid(node, err)
+		}
+		return sc
+	}
+
+	inner := sc
+	for _, v := range config.Across {
+		inner.vars = withResources(inner.vars, v.Var)
+	}
+
+	var after scope
+
+	switch {
+	case config.Aggregate != nil:
+		after = w.walkParallel(sequenceItems(mappingValue(node, "aggregate")), inner)
+
+	case config.InParallel.Kind != 0:
+		after = w.walkParallel(parallelSteps(node), inner)
+
+	case config.Do != nil:
+		after = w.walkPlan(sequenceItems(mappingValue(node, "do")), inner)
+
+	case config.Try != nil:
+		// The tried step may fail without failing the build, so what it
+		// produces may not be present.
+		tried := w.walkStep(mappingValue(node, "try"), inner)
+		after = tried
+		after.resources = inner.resources
+		after.uncertain = withResources(tried.uncertain, tried.resources[len(inner.resources):]...)
+
+	case config.Get != "", config.Put != "", config.Task != "", config.SetPipeline != "", config.LoadVar != "":
+		after = inner.with(w.visit(step{config: config, node: node}, inner)...)
+		if config.LoadVar != "" {
+			after.vars = withResources(after.vars, config.LoadVar)
+		}
+
+	default:
+		after = inner
+	}
+
+	// Vars set across the step are only visible within it.
+	after.vars = withResources(sc.vars, after.vars[len(inner.vars):]...)
+
+	for _, key := range []string{"on_failure", "on_error", "on_abort"} {
+		if hook := mappingValue(node, key); hook != nil {
+			w.walkStep(hook, after)
+		}
+	}
+
+	if hook := mappingValue(node, "on_success"); hook != nil {
+		after = w.walkStep(hook, after)
+	}
+
+	// The ensure hook runs even when the step fails, so what the step
+	// produced may not be present for it. Steps after the step only run when
+	// it succeeded, and see everything it produced.
+	if hook := mappingValue(node, "ensure"); hook != nil {
+		ensured := sc
+		ensured.uncertain = withResources(after.uncertain, after.resources[len(sc.resources):]...)
+
+		result := w.walkStep(hook, ensured)
+		after = after.with(result.resources[len(sc.resources):]...)
+		after.vars = withResources(after.vars, result.vars[len(sc.vars):]...)
+	}
+
+	return after
+}
+
+// walkParallel visits steps that run in parallel. Each branch sees only what
+// was present before the steps started, and what the other branches produce
+// is recorded as siblings. Everything the branches produce is present once
+// they have all completed.
+func (w planWalker) walkParallel(items []*yaml.Node, sc scope) scope {
+	dry := planWalker{
+		visit:   func(s step, _ scope) []string { return w.outputs(s) },
+		outputs: w.outputs,
+	}
+
+	produced := make([][]string, len(items))
+	for i, item := range items {
+		result := dry.walkStep(item, scope{})
+		produced[i] = withResources(result.resources, result.uncertain...)
+	}
+
+	after := sc
+	for i, item := range items {
+		branch := sc
+		for j := range produced {
+			if j != i {
+				branch.siblings = withResources(branch.siblings, produced[j]...)
+			}
+		}
+
+		result := w.walkStep(item, branch)
+		after = after.with(result.resources[len(sc.resources):]...)
+		after.uncertain = withResources(after.uncertain, result.uncertain[len(sc.uncertain):]...)
+		after.vars = withResources(after.vars, result.vars[len(sc.vars):]...)
+	}
+
+	return after
+}
+
+// parallelSteps returns the steps of an in_parallel step, which are either
+// given as a list or under the steps key alongside limit and fail_fast.
+func parallelSteps(stepNode *yaml.Node) []*yaml.Node {
+	inParallel := mappingValue(stepNode, "in_parallel")
+	if inParallel != nil && inParallel.Kind == yaml.MappingNode {
+		return sequenceItems(mappingValue(inParallel, "steps"))
+	}
+
+	return sequenceItems(inParallel)
+}
+
+// withResources returns a new slice so that sibling scopes never share a
+// backing array.
+func withResources(resources []string, more ...string) []string {
+	result := make([]string, 0, len(resources)+len(more))
+	result = append(result, resources...)
+	return append(result, more...)
+}
@@ -0,0 +1,170 @@
+package testpipe
+
+import yaml "gopkg.in/yaml.v3"
+
+// Position is a location in a YAML file. Line and Column are 1-based and
+// zero when unknown.
+type Position struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// positionError is an error that knows where in a file it was caused.
+type positionError struct {
+	Position
+	err error
+}
+
+func (e *positionError) Error() string {
+	return e.err.Error()
+}
+
+func nodePosition(file string, node *yaml.Node) Position {
+	if node == nil {
+		return Position{File: file}
+	}
+
+	return Position{
+		File:   file,
+		Line:   node.Line,
+		Column: node.Column,
+	}
+}
+
+// keyPosition returns the position of key within a mapping node, or of the
+// node itself when the key is absent.
+func keyPosition(file string, node *yaml.Node, key string) Position {
+	if keyNode := mappingKey(node, key); keyNode != nil {
+		return nodePosition(file, keyNode)
+	}
+
+	return nodePosition(file, resolveNode(node))
+}
+
+// decodeNode decodes a parsed document into v, leaving v untouched when the
+// document is empty.
+func decodeNode(node *yaml.Node, v interface{}) error {
+	if resolveNode(node) == nil {
+		return nil
+	}
+
+	return node.Decode(v)
+}
+
+// resolveNode follows document and alias nodes to the node holding content.
+func resolveNode(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil
+			}
+			node = node.Content[0]
+		case yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+
+	return nil
+}
+
+// mappingKey returns the key node for key in a mapping node, looking through
+// merge keys, or nil if the key is absent.
+func mappingKey(node *yaml.Node, key string) *yaml.Node {
+	keyNode, _ := mappingEntry(node, key)
+	return keyNode
+}
+
+// mappingValue returns the value node for key in a mapping node, looking
+// through merge keys, or nil if the key is absent.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	_, valueNode := mappingEntry(node, key)
+	return resolveNode(valueNode)
+}
+
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	node = resolveNode(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "<<" {
+			continue
+		}
+
+		merged := resolveNode(node.Content[i+1])
+		if merged != nil && merged.Kind == yaml.SequenceNode {
+			for _, m := range merged.Content {
+				if k, v := mappingEntry(m, key); k != nil {
+					return k, v
+				}
+			}
+		} else if k, v := mappingEntry(merged, key); k != nil {
+			return k, v
+		}
+	}
+
+	return nil, nil
+}
+
+// sequenceItems returns the resolved items of a sequence node.
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	node = resolveNode(node)
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	items := make([]*yaml.Node, len(node.Content))
+	for i := range node.Content {
+		items[i] = resolveNode(node.Content[i])
+	}
+
+	return items
+}
+
+// itemAt returns the i-th item of a list of nodes, or nil when out of range.
+func itemAt(items []*yaml.Node, i int) *yaml.Node {
+	if i < 0 || i >= len(items) {
+		return nil
+	}
+
+	return items[i]
+}
+
+// mappingKeys returns the key nodes of a mapping node, including those pulled
+// in through merge keys.
+func mappingKeys(node *yaml.Node) []*yaml.Node {
+	node = resolveNode(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var keys []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "<<" {
+			keys = append(keys, node.Content[i])
+			continue
+		}
+
+		merged := resolveNode(node.Content[i+1])
+		if merged != nil && merged.Kind == yaml.SequenceNode {
+			for _, m := range merged.Content {
+				keys = append(keys, mappingKeys(m)...)
+			}
+		} else {
+			keys = append(keys, mappingKeys(merged)...)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,312 @@
+package testpipe
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/concourse/atc"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// builtinResourceTypes are the resource types shipped with Concourse workers,
+// which pipelines may use without declaring them in resource_types.
+var builtinResourceTypes = []string{
+	"bosh-io-release",
+	"bosh-io-stemcell",
+	"cf",
+	"docker-image",
+	"git",
+	"github-release",
+	"hg",
+	"mock",
+	"pool",
+	"registry-image",
+	"s3",
+	"semver",
+	"time",
+	"tracker",
+}
+
+// resourceName returns the name of the resource a get or put step refers to,
+// along with the key it was given under.
+func resourceName(planConfig atc.PlanConfig) (string, string) {
+	if planConfig.Resource != "" {
+		return planConfig.Resource, "resource"
+	}
+
+	if planConfig.Get != "" {
+		return planConfig.Get, "get"
+	}
+
+	return planConfig.Put, "put"
+}
+
+func testDeclarationOfResource(
+	config atc.Config,
+	s step,
+	jobName string,
+	pipelinePath string,
+) []Finding {
+	name, key := resourceName(s.config.PlanConfig)
+
+	for _, resource := range config.Resources {
+		if resource.Name == name {
+			return nil
+		}
+	}
+
+	return []Finding{{
+		Rule:         RuleUndeclaredResource,
+		Kind:         "resources",
+		PipelinePath: pipelinePath,
+		JobName:      jobName,
+		TaskName:     s.config.Name(),
+		Position:     s.position(pipelinePath, key),
+		Detail:       "Step refers to a resource that is not declared",
+		Missing:      []string{name},
+	}}
+}
+
+func testDeclarationOfResourceTypes(
+	config atc.Config,
+	root *yaml.Node,
+	pipelinePath string,
+) []Finding {
+	known := map[string]bool{}
+	for _, name := range builtinResourceTypes {
+		known[name] = true
+	}
+	for _, resourceType := range config.ResourceTypes {
+		known[resourceType.Name] = true
+	}
+
+	var findings []Finding
+
+	resourceNodes := sequenceItems(mappingValue(root, "resources"))
+	for i, resource := range config.Resources {
+		if known[resource.Type] {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:         RuleUnknownResourceType,
+			Kind:         "resource types",
+			PipelinePath: pipelinePath,
+			TaskName:     resource.Name,
+			Position:     keyPosition(pipelinePath, itemAt(resourceNodes, i), "type"),
+			Detail:       "Resource has a type that is neither built in nor declared",
+			Missing:      []string{resource.Type},
+		})
+	}
+
+	resourceTypeNodes := sequenceItems(mappingValue(root, "resource_types"))
+	for i, resourceType := range config.ResourceTypes {
+		if known[resourceType.Type] {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:         RuleUnknownResourceType,
+			Kind:         "resource types",
+			PipelinePath: pipelinePath,
+			TaskName:     resourceType.Name,
+			Position:     keyPosition(pipelinePath, itemAt(resourceTypeNodes, i), "type"),
+			Detail:       "Resource type has a type that is neither built in nor declared",
+			Missing:      []string{resourceType.Type},
+		})
+	}
+
+	return findings
+}
+
+// resourcesOf returns the names of the resources got or put by steps.
+func resourcesOf(steps []step) map[string]bool {
+	resources := map[string]bool{}
+	for _, s := range steps {
+		if s.config.Get != "" || s.config.Put != "" {
+			name, _ := resourceName(s.config.PlanConfig)
+			resources[name] = true
+		}
+	}
+
+	return resources
+}
+
+// testUsageOfResources reports resources that no step gets or puts, and
+// resource types that no resource, resource type or task image uses. Resource
+// types are left alone when some tasks couldn't be loaded, since their
+// images may use them.
+func testUsageOfResources(
+	config atc.Config,
+	root *yaml.Node,
+	jobSteps map[string][]step,
+	tasks []atc.PlanConfig,
+	unloadedTasks bool,
+	pipelinePath string,
+) []Finding {
+	usedResources := map[string]bool{}
+	for _, steps := range jobSteps {
+		for name := range resourcesOf(steps) {
+			usedResources[name] = true
+		}
+	}
+
+	usedTypes := map[string]bool{}
+	for _, resource := range config.Resources {
+		usedTypes[resource.Type] = true
+	}
+	for _, resourceType := range config.ResourceTypes {
+		usedTypes[resourceType.Type] = true
+	}
+	for _, task := range tasks {
+		if task.TaskConfig.ImageResource != nil {
+			usedTypes[task.TaskConfig.ImageResource.Type] = true
+		}
+	}
+
+	var findings []Finding
+
+	resourceNodes := sequenceItems(mappingValue(root, "resources"))
+	for i, resource := range config.Resources {
+		if usedResources[resource.Name] {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:         RuleUnusedResource,
+			Kind:         "resources",
+			PipelinePath: pipelinePath,
+			TaskName:     resource.Name,
+			Position:     keyPosition(pipelinePath, itemAt(resourceNodes, i), "name"),
+			Detail:       "Resource is not used by any job",
+			Extras:       []string{resource.Name},
+		})
+	}
+
+	if unloadedTasks {
+		return findings
+	}
+
+	resourceTypeNodes := sequenceItems(mappingValue(root, "resource_types"))
+	for i, resourceType := range config.ResourceTypes {
+		if usedTypes[resourceType.Name] {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:         RuleUnusedResourceType,
+			Kind:         "resource types",
+			PipelinePath: pipelinePath,
+			TaskName:     resourceType.Name,
+			Position:     keyPosition(pipelinePath, itemAt(resourceTypeNodes, i), "name"),
+			Detail:       "Resource type is not used by any resource or task",
+			Extras:       []string{resourceType.Name},
+		})
+	}
+
+	return findings
+}
+
+// testPassedConstraints reports gets whose passed constraints can never be
+// satisfied, because a listed job doesn't exist, doesn't get or put the same
+// resource, or is the job doing the get.
+func testPassedConstraints(
+	config atc.Config,
+	jobSteps map[string][]step,
+	pipelinePath string,
+) []Finding {
+	jobResources := map[string]map[string]bool{}
+	for _, job := range config.Jobs {
+		jobResources[job.Name] = resourcesOf(jobSteps[job.Name])
+	}
+
+	var findings []Finding
+
+	for _, job := range config.Jobs {
+		for _, s := range jobSteps[job.Name] {
+			if s.config.Get == "" || len(s.config.Passed) == 0 {
+				continue
+			}
+
+			name, _ := resourceName(s.config.PlanConfig)
+
+			var undefined, unrelated, circular []string
+			for _, passed := range s.config.Passed {
+				resources, ok := jobResources[passed]
+				switch {
+				case !ok:
+					undefined = append(undefined, passed)
+				case passed == job.Name:
+					circular = append(circular, passed)
+				case !resources[name]:
+					unrelated = append(unrelated, passed)
+				}
+			}
+
+			finding := Finding{
+				Rule:         RulePassedConstraints,
+				Kind:         "jobs",
+				PipelinePath: pipelinePath,
+				JobName:      job.Name,
+				TaskName:     s.config.Name(),
+				Position:     s.position(pipelinePath, "passed"),
+			}
+
+			if len(undefined) > 0 {
+				finding.Detail = "Get is constrained by jobs that do not exist"
+				finding.Extras = undefined
+				findings = append(findings, finding)
+			}
+
+			if len(unrelated) > 0 {
+				finding.Detail = fmt.Sprintf("Get is constrained by jobs that do not get or put %s", name)
+				finding.Extras = unrelated
+				findings = append(findings, finding)
+			}
+
+			if len(circular) > 0 {
+				finding.Detail = "Get is constrained by its own job"
+				finding.Extras = circular
+				findings = append(findings, finding)
+			}
+		}
+	}
+
+	return findings
+}
+
+// fileResources returns the resources that steps load files from, under the
+// names they are declared with rather than those a get renames them to.
+func fileResources(jobSteps map[string][]step) []string {
+	var resources []string
+
+	for _, steps := range jobSteps {
+		renamed := map[string]string{}
+		for _, s := range steps {
+			if s.config.Get != "" && s.config.Resource != "" {
+				renamed[s.config.Get] = s.config.Resource
+			}
+		}
+
+		for _, s := range steps {
+			var paths []string
+			if s.config.TaskConfigPath != "" {
+				paths = append(paths, s.config.TaskConfigPath)
+			}
+			paths = append(paths, s.config.VarFiles...)
+
+			for _, path := range paths {
+				root := artifactRoot(path)
+				if name, ok := renamed[root]; ok {
+					root = name
+				}
+
+				resources = appendUnique(resources, root)
+			}
+		}
+	}
+
+	sort.Strings(resources)
+
+	return resources
+}
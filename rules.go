@@ -0,0 +1,603 @@
+package testpipe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/concourse/atc"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Severity says how much a finding matters.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// RuleConfig configures a rule in Config.
+type RuleConfig struct {
+	Disabled bool     `yaml:"disabled"`
+	Severity Severity `yaml:"severity"`
+
+	// Options are specific to each rule, and given to it as
+	// Context.Options.
+	Options map[string]interface{} `yaml:"options"`
+}
+
+// Rule is a check run over every pipeline, job and step that Run lints.
+// Programs embedding testpipe can add rules of their own with Register.
+type Rule interface {
+	// ID identifies the rule in findings and config, and must not change
+	// once released.
+	ID() string
+
+	// Description says what the rule looks for.
+	Description() string
+
+	// DefaultSeverity is the severity of the rule's findings.
+	DefaultSeverity() Severity
+
+	// Check returns the findings for a pipeline, job or step. Findings that
+	// leave Rule, Severity, PipelinePath, JobName, TaskName or Position.File
+	// empty are given those of the rule and the context. The severity set in
+	// Config.Rules takes precedence over that of the findings.
+	Check(ctx *Context) []Finding
+}
+
+// Context is what a Rule checks: a pipeline as a whole, one of its jobs, or
+// one of the steps of a job, including those nested in other steps and in
+// hooks. Each job is checked ahead of its steps, and the pipeline once all
+// of its jobs have been.
+type Context struct {
+	PipelinePath string
+	Pipeline     atc.Config
+
+	// Job is the job being checked, or whose step is being checked. It is
+	// nil when checking the pipeline.
+	Job *atc.JobConfig
+
+	// Step is the step being checked, or nil when checking a job or the
+	// pipeline. The TaskConfig of a task step is the one loaded from its
+	// file when it has one.
+	Step *atc.PlanConfig
+
+	// Resources are the artifacts sure to be present when Step runs.
+	Resources []string
+
+	// Options are those given to the rule being checked in Config.Rules.
+	Options map[string]interface{}
+
+	node *yaml.Node
+
+	// Set for steps.
+	step         step
+	scope        scope
+	resourceMap  map[string]string
+	tasks        *TaskCache
+	task         *atc.PlanConfig
+	taskErr      error
+	childErr     error
+	interpolated map[*yaml.Node]bool
+
+	// Set for jobs.
+	invalid []invalidStep
+
+	// Set for the pipeline.
+	jobSteps      map[string][]step
+	allTasks      []atc.PlanConfig
+	unloadedTasks bool
+	vars          map[string]interface{}
+	ownVars       map[string]interface{}
+	varUses       []varUse
+	rules         map[string]RuleConfig
+
+	suppressions []*suppression
+
+	// memos are the findings of checks that report under several rules.
+	memos map[string][]Finding
+}
+
+// invalidStep is a step that could not be decoded.
+type invalidStep struct {
+	node *yaml.Node
+	err  error
+}
+
+// Position returns the position of key within the step, job or pipeline
+// being checked, or of the step, job or pipeline itself when key is absent.
+func (ctx *Context) Position(key string) Position {
+	return keyPosition(ctx.PipelinePath, ctx.node, key)
+}
+
+func (ctx *Context) jobName() string {
+	if ctx.Job == nil {
+		return ""
+	}
+
+	return ctx.Job.Name
+}
+
+func (ctx *Context) isPipeline() bool {
+	return ctx.Job == nil
+}
+
+func (ctx *Context) isJob() bool {
+	return ctx.Job != nil && ctx.Step == nil
+}
+
+func (ctx *Context) isStep() bool {
+	return ctx.Step != nil
+}
+
+// memo returns the findings of a check that reports under several rules,
+// running it only once for the context.
+func (ctx *Context) memo(key string, check func() []Finding) []Finding {
+	if findings, ok := ctx.memos[key]; ok {
+		return findings
+	}
+
+	if ctx.memos == nil {
+		ctx.memos = map[string][]Finding{}
+	}
+
+	findings := check()
+	ctx.memos[key] = findings
+
+	return findings
+}
+
+// requiredResources returns the findings of testPresenceOfRequiredResources
+// for a task step.
+func (ctx *Context) requiredResources() []Finding {
+	return ctx.memo("required-resources", func() []Finding {
+		return testPresenceOfRequiredResources(ctx.scope, ctx.task, ctx.step, ctx.jobName(), ctx.PipelinePath)
+	})
+}
+
+// taskValidity returns the findings of testValidityOfTask for a task step.
+func (ctx *Context) taskValidity() []Finding {
+	return ctx.memo("task-validity", func() []Finding {
+		return testValidityOfTask(ctx.task, ctx.tasks, ctx.resourceMap, ctx.step, ctx.jobName(), ctx.PipelinePath)
+	})
+}
+
+// stepFiles returns the findings of testPresenceOfFiles for a set_pipeline
+// or load_var step.
+func (ctx *Context) stepFiles() []Finding {
+	return ctx.memo("step-files", func() []Finding {
+		return testPresenceOfFiles(ctx.scope, ctx.resourceMap, ctx.step, ctx.jobName(), ctx.PipelinePath)
+	})
+}
+
+// resourceUsage returns the findings of testUsageOfResources for the
+// pipeline.
+func (ctx *Context) resourceUsage() []Finding {
+	return ctx.memo("resource-usage", func() []Finding {
+		return testUsageOfResources(ctx.Pipeline, ctx.node, ctx.jobSteps, ctx.allTasks, ctx.unloadedTasks, ctx.PipelinePath)
+	})
+}
+
+// check runs rule over ctx, filling in what its findings leave out.
+func (ctx *Context) check(rule Rule, config RuleConfig) []Finding {
+	ctx.Options = config.Options
+
+	findings := rule.Check(ctx)
+	for i := range findings {
+		f := &findings[i]
+		if f.Rule == "" {
+			f.Rule = rule.ID()
+		}
+		if config.Severity != "" {
+			f.Severity = config.Severity
+		} else if f.Severity == "" {
+			f.Severity = rule.DefaultSeverity()
+		}
+		if f.PipelinePath == "" {
+			f.PipelinePath = ctx.PipelinePath
+		}
+		if f.JobName == "" {
+			f.JobName = ctx.jobName()
+		}
+		if f.TaskName == "" && ctx.isStep() {
+			f.TaskName = ctx.step.config.Name()
+		}
+		if f.File == "" {
+			f.File = ctx.PipelinePath
+		}
+	}
+
+	if rule.ID() == RuleUnusedSuppressions {
+		return findings
+	}
+
+	return suppress(ctx, findings)
+}
+
+// configureFindings gives findings made outside of Run the severity their
+// rule is configured with, or its default, leaving out those of disabled
+// rules.
+func configureFindings(findings []Finding, rules map[string]RuleConfig) []Finding {
+	var configured []Finding
+	for _, finding := range findings {
+		rule := rules[finding.Rule]
+		if rule.Disabled {
+			continue
+		}
+
+		finding.Severity = rule.Severity
+		if finding.Severity == "" {
+			finding.Severity = defaultSeverity(finding.Rule)
+		}
+
+		configured = append(configured, finding)
+	}
+
+	return configured
+}
+
+// builtinRule is a rule shipped with testpipe.
+type builtinRule struct {
+	id          string
+	severity    Severity
+	description string
+	check       func(ctx *Context) []Finding
+}
+
+func (r builtinRule) ID() string                   { return r.id }
+func (r builtinRule) Description() string          { return r.description }
+func (r builtinRule) DefaultSeverity() Severity    { return r.severity }
+func (r builtinRule) Check(ctx *Context) []Finding { return r.check(ctx) }
+
+// builtinRules are in the order their findings are reported in for each
+// pipeline, job and step.
+var builtinRules = []Rule{
+	builtinRule{RuleParamsParity, SeverityError, "Task params given by a step match the params the task declares", checkParamsParity},
+	builtinRule{RuleLocalVars, SeverityError, "Local vars are set by load_var or across before they are used", checkLocalVars},
+	builtinRule{RuleRequiredInputs, SeverityError, "Every input of a task, and every resource a step loads files from, is present when it runs", checkRequiredInputs},
+	builtinRule{RuleTaskDefinition, SeverityError, "Every task has a config with a path to run, and standalone tasks have a platform and image", checkTaskDefinition},
+	builtinRule{RuleStepKeys, SeverityError, "Steps have no keys that Concourse would ignore", checkStepKeys},
+	builtinRule{RuleUndeclaredResource, SeverityError, "Every get and put refers to a declared resource", checkUndeclaredResource},
+	builtinRule{RuleUnknownResourceType, SeverityError, "Every resource and resource type has a built-in or declared type", checkUnknownResourceType},
+	builtinRule{RuleUnusedResource, SeverityError, "Every resource is used by a job", checkUnusedResources(RuleUnusedResource)},
+	builtinRule{RuleUnusedResourceType, SeverityError, "Every resource type is used by a resource, resource type or task image", checkUnusedResources(RuleUnusedResourceType)},
+	builtinRule{RulePassedConstraints, SeverityError, "Passed constraints name other jobs that get or put the same resource", checkPassedConstraints},
+	builtinRule{RuleHookInputs, SeverityError, "Hooks and steps after a try don't rely on resources that a failed step may not have produced", checkTaskInputs(RuleHookInputs)},
+	builtinRule{RuleParallelInputs, SeverityError, "Steps don't rely on resources produced by steps running in parallel", checkTaskInputs(RuleParallelInputs)},
+	builtinRule{RuleStepDefinition, SeverityError, "Every step can be unmarshaled", checkStepDefinition},
+	builtinRule{RuleStepFiles, SeverityError, "Files loaded by set_pipeline and load_var steps exist", checkStepFiles},
+	builtinRule{RulePipelineVars, SeverityError, "The vars given by a set_pipeline step match the vars the pipeline uses", checkPipelineVars},
+	builtinRule{RuleUndefinedVars, SeverityError, "Every var the pipeline uses is provided", checkUndefinedVars},
+	builtinRule{RuleVarInterpolation, SeverityError, "Vars interpolated into a larger string have string, number or boolean values", checkVarInterpolation},
+	builtinRule{RuleUnusedVars, SeverityError, "Every var provided is used by the pipeline or its tasks", checkUnusedVars},
+	builtinRule{RuleTaskKeys, SeverityError, "Tasks have no unknown keys", checkTaskValidity(RuleTaskKeys)},
+	builtinRule{RuleTaskArtifacts, SeverityError, "Task inputs and outputs have distinct names and paths", checkTaskValidity(RuleTaskArtifacts)},
+}
+
+// unusedSuppressions is checked once every other rule has been, including
+// those added with Register, so that every finding a suppression could
+// suppress is known.
+var unusedSuppressions = builtinRule{RuleUnusedSuppressions, SeverityWarning, "Every testpipe:ignore comment suppresses a finding", checkUnusedSuppressions}
+
+// declarationRules check what the pipeline declares, and their findings are
+// reported ahead of those for its jobs.
+var declarationRules = []string{RuleUnknownResourceType}
+
+// checksDeclarations reports whether rule is one of declarationRules.
+func checksDeclarations(rule Rule) bool {
+	_, builtin := rule.(builtinRule)
+	return builtin && contains(declarationRules, rule.ID())
+}
+
+var registry = struct {
+	sync.RWMutex
+	rules []Rule
+}{rules: builtinRules}
+
+// Register adds a rule to those run by every subsequent call to Run. It
+// panics if a rule with the same ID is already registered.
+func Register(rule Rule) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if rule.ID() == unusedSuppressions.ID() {
+		panic(fmt.Sprintf("testpipe: rule %s is already registered", rule.ID()))
+	}
+
+	for _, r := range registry.rules {
+		if r.ID() == rule.ID() {
+			panic(fmt.Sprintf("testpipe: rule %s is already registered", rule.ID()))
+		}
+	}
+
+	registry.rules = append(registry.rules, rule)
+}
+
+// Rules returns the built-in rules followed by those added with Register, in
+// the order they are run, ending with the rule reporting unused suppressions.
+func Rules() []Rule {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	rules := make([]Rule, 0, len(registry.rules)+1)
+	rules = append(rules, registry.rules...)
+	return append(rules, unusedSuppressions)
+}
+
+// defaultSeverity returns the default severity of the rule with the given
+// ID, or SeverityError for rules that aren't registered.
+func defaultSeverity(id string) Severity {
+	for _, rule := range Rules() {
+		if rule.ID() == id {
+			return rule.DefaultSeverity()
+		}
+	}
+
+	return SeverityError
+}
+
+// Validate reports rules in Config.Rules that aren't registered or are given
+// a severity that doesn't exist.
+func (c Config) Validate() error {
+	known := map[string]bool{}
+	for _, rule := range Rules() {
+		known[rule.ID()] = true
+	}
+
+	var ids []string
+	for id := range c.Rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if !known[id] {
+			return fmt.Errorf("unknown rule in config: %s", id)
+		}
+
+		switch c.Rules[id].Severity {
+		case "", SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return fmt.Errorf("invalid severity for rule %s: %s", id, c.Rules[id].Severity)
+		}
+	}
+
+	return nil
+}
+
+// stringsOption returns an option given as a list of strings.
+func stringsOption(options map[string]interface{}, key string) []string {
+	values, _ := options[key].([]interface{})
+
+	var result []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
+// findingsOf returns the findings reported under rule, for checks that
+// report under several.
+func findingsOf(rule string, findings []Finding) []Finding {
+	var result []Finding
+	for _, f := range findings {
+		if f.Rule == rule {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+func checkParamsParity(ctx *Context) []Finding {
+	if ctx.task == nil {
+		return nil
+	}
+
+	return testParityOfParams(ctx.task, stringsOption(ctx.Options, "allowed_extra_params"), ctx.step, ctx.interpolated, ctx.jobName(), ctx.PipelinePath)
+}
+
+func checkRequiredInputs(ctx *Context) []Finding {
+	switch {
+	case ctx.task != nil:
+		return findingsOf(RuleRequiredInputs, ctx.requiredResources())
+	case ctx.isStep() && (ctx.step.config.SetPipeline != "" || ctx.step.config.LoadVar != ""):
+		return findingsOf(RuleRequiredInputs, ctx.stepFiles())
+	}
+
+	return nil
+}
+
+func checkTaskInputs(rule string) func(ctx *Context) []Finding {
+	return func(ctx *Context) []Finding {
+		if ctx.task == nil {
+			return nil
+		}
+
+		return findingsOf(rule, ctx.requiredResources())
+	}
+}
+
+func checkTaskDefinition(ctx *Context) []Finding {
+	if ctx.taskErr != nil {
+		pos := ctx.step.position(ctx.PipelinePath, "task")
+		if perr, ok := ctx.taskErr.(*positionError); ok {
+			pos = perr.Position
+		}
+
+		return []Finding{{
+			Rule:         RuleTaskDefinition,
+			Kind:         "task",
+			PipelinePath: ctx.PipelinePath,
+			JobName:      ctx.jobName(),
+			TaskName:     ctx.step.config.Name(),
+			Position:     pos,
+			Detail:       ctx.taskErr.Error(),
+		}}
+	}
+
+	return checkTaskValidity(RuleTaskDefinition)(ctx)
+}
+
+func checkTaskValidity(rule string) func(ctx *Context) []Finding {
+	return func(ctx *Context) []Finding {
+		if ctx.task == nil {
+			return nil
+		}
+
+		return findingsOf(rule, ctx.taskValidity())
+	}
+}
+
+func checkStepKeys(ctx *Context) []Finding {
+	if !ctx.isJob() {
+		return nil
+	}
+
+	findings := testValidityOfKeys(mappingValue(ctx.node, "plan"), ctx.jobName(), ctx.PipelinePath)
+	for _, key := range jobHookKeys {
+		if hookNode := mappingValue(ctx.node, key); hookNode != nil {
+			findings = append(findings, testValidityOfStepKeys(hookNode, ctx.jobName(), ctx.PipelinePath)...)
+		}
+	}
+
+	return findings
+}
+
+func checkUndeclaredResource(ctx *Context) []Finding {
+	if !ctx.isStep() || (ctx.Step.Get == "" && ctx.Step.Put == "") {
+		return nil
+	}
+
+	return testDeclarationOfResource(ctx.Pipeline, ctx.step, ctx.jobName(), ctx.PipelinePath)
+}
+
+func checkUnknownResourceType(ctx *Context) []Finding {
+	if !ctx.isPipeline() {
+		return nil
+	}
+
+	return testDeclarationOfResourceTypes(ctx.Pipeline, ctx.node, ctx.PipelinePath)
+}
+
+func checkUnusedResources(rule string) func(ctx *Context) []Finding {
+	return func(ctx *Context) []Finding {
+		if !ctx.isPipeline() {
+			return nil
+		}
+
+		return findingsOf(rule, ctx.resourceUsage())
+	}
+}
+
+func checkPassedConstraints(ctx *Context) []Finding {
+	if !ctx.isPipeline() {
+		return nil
+	}
+
+	return testPassedConstraints(ctx.Pipeline, ctx.jobSteps, ctx.PipelinePath)
+}
+
+func checkStepDefinition(ctx *Context) []Finding {
+	var findings []Finding
+	for _, invalid := range ctx.invalid {
+		findings = append(findings, Finding{
+			Rule:         RuleStepDefinition,
+			Kind:         "step",
+			PipelinePath: ctx.PipelinePath,
+			JobName:      ctx.jobName(),
+			Position:     nodePosition(ctx.PipelinePath, invalid.node),
+			Detail:       fmt.Sprintf("Step cannot be unmarshaled: %s", invalid.err),
+		})
+	}
+
+	return findings
+}
+
+func checkStepFiles(ctx *Context) []Finding {
+	if !ctx.isStep() || (ctx.step.config.SetPipeline == "" && ctx.step.config.LoadVar == "") {
+		return nil
+	}
+
+	findings := findingsOf(RuleStepFiles, ctx.stepFiles())
+
+	if ctx.childErr != nil {
+		findings = append(findings, Finding{
+			Rule:         RuleStepFiles,
+			Kind:         "files",
+			PipelinePath: ctx.PipelinePath,
+			JobName:      ctx.jobName(),
+			TaskName:     ctx.step.config.Name(),
+			Position:     ctx.step.position(ctx.PipelinePath, "file"),
+			Detail:       ctx.childErr.Error(),
+		})
+	}
+
+	return findings
+}
+
+func checkLocalVars(ctx *Context) []Finding {
+	if !ctx.isStep() {
+		return nil
+	}
+
+	return testDefinitionOfLocalVars(ctx.scope, ctx.step, ctx.jobName(), ctx.PipelinePath)
+}
+
+func checkPipelineVars(ctx *Context) []Finding {
+	if !ctx.isStep() || ctx.step.config.SetPipeline == "" {
+		return nil
+	}
+
+	path, ok := resourcePath(ctx.resourceMap, ctx.step.config.TaskConfigPath)
+	if !ok {
+		return nil
+	}
+
+	// Files that can't be read are reported by testPresenceOfFiles.
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	return testParityOfPipelineVars(bs, ctx.resourceMap, ctx.step, ctx.jobName(), ctx.PipelinePath)
+}
+
+func checkUndefinedVars(ctx *Context) []Finding {
+	if !ctx.isPipeline() || ctx.vars == nil {
+		return nil
+	}
+
+	return testDefinitionOfVars(ctx.varUses, ctx.PipelinePath)
+}
+
+func checkVarInterpolation(ctx *Context) []Finding {
+	if !ctx.isPipeline() || ctx.vars == nil {
+		return nil
+	}
+
+	return testInterpolationOfVars(ctx.varUses, ctx.PipelinePath)
+}
+
+func checkUnusedVars(ctx *Context) []Finding {
+	if !ctx.isPipeline() || ctx.vars == nil {
+		return nil
+	}
+
+	return testUsageOfVars(ctx.ownVars, varNames(ctx.varUses), ctx.PipelinePath)
+}
+
+func checkUnusedSuppressions(ctx *Context) []Finding {
+	if !ctx.isPipeline() {
+		return nil
+	}
+
+	return testUsageOfSuppressions(ctx.suppressions, ctx.rules, ctx.PipelinePath)
+}
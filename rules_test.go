@@ -0,0 +1,137 @@
+package testpipe_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/krishicks/testpipe"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type noLatestTags struct{}
+
+func (noLatestTags) ID() string                         { return "no-latest-tags" }
+func (noLatestTags) Description() string                { return "Tasks don't set a latest tag" }
+func (noLatestTags) DefaultSeverity() testpipe.Severity { return testpipe.SeverityWarning }
+
+func (noLatestTags) Check(ctx *testpipe.Context) []testpipe.Finding {
+	if ctx.Step == nil || ctx.Step.Params["tag"] != "latest" {
+		return nil
+	}
+
+	return []testpipe.Finding{{
+		Kind:     "params",
+		Position: ctx.Position("params"),
+		Detail:   "Task sets a latest tag",
+	}}
+}
+
+// Rules stay registered for the rest of the suite, so the rule is only
+// registered once.
+var registerRule sync.Once
+
+var _ = Describe("Register", func() {
+	var (
+		tmpDir       string
+		pipelinePath string
+	)
+
+	BeforeEach(func() {
+		registerRule.Do(func() {
+			testpipe.Register(noLatestTags{})
+		})
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "testpipe")
+		Expect(err).NotTo(HaveOccurred())
+
+		pipelinePath = filepath.Join(tmpDir, "pipeline.yml")
+		pipelineConfig := `---
+jobs:
+- name: some-job
+  plan:
+  - task: some-task
+    config:
+      params:
+        tag:
+      run:
+        path: some-command
+    params:
+      tag: latest
+`
+
+		err = ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("runs the rule after the built-in rules, before unused suppressions are reported", func() {
+		rules := testpipe.Rules()
+		Expect(rules[len(rules)-2].ID()).To(Equal("no-latest-tags"))
+		Expect(rules[len(rules)-1].ID()).To(Equal("unused-suppressions"))
+	})
+
+	It("reports the rule's findings with the context filled in", func() {
+		findings, err := testpipe.New(pipelinePath, testpipe.Config{}).Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(findings).To(HaveLen(1))
+		Expect(findings[0].Rule).To(Equal("no-latest-tags"))
+		Expect(findings[0].Severity).To(Equal(testpipe.SeverityWarning))
+		Expect(findings[0].PipelinePath).To(Equal(pipelinePath))
+		Expect(findings[0].JobName).To(Equal("some-job"))
+		Expect(findings[0].TaskName).To(Equal("some-task"))
+		Expect(findings[0].File).To(Equal(pipelinePath))
+		Expect(findings[0].Line).To(Equal(11))
+	})
+
+	It("gives the rule's findings the severity set in the config", func() {
+		config := testpipe.Config{
+			Rules: map[string]testpipe.RuleConfig{
+				"no-latest-tags": {Severity: testpipe.SeverityError},
+			},
+		}
+
+		findings, err := testpipe.New(pipelinePath, config).Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(findings).To(HaveLen(1))
+		Expect(findings[0].Severity).To(Equal(testpipe.SeverityError))
+	})
+
+	It("lets the rule's findings be suppressed without the suppression being reported as unused", func() {
+		pipelineConfig := `---
+jobs:
+- name: some-job
+  plan:
+  - task: some-task # testpipe:ignore no-latest-tags
+    config:
+      params:
+        tag:
+      run:
+        path: some-command
+    params:
+      tag: latest
+`
+
+		err := ioutil.WriteFile(pipelinePath, []byte(pipelineConfig), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		findings, err := testpipe.New(pipelinePath, testpipe.Config{}).Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings).To(BeEmpty())
+	})
+
+	It("panics when a rule with the same ID is already registered", func() {
+		Expect(func() {
+			testpipe.Register(noLatestTags{})
+		}).To(PanicWith("testpipe: rule no-latest-tags is already registered"))
+	})
+})
@@ -0,0 +1,204 @@
+package testpipe
+
+import (
+	"os"
+	"sort"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// nestedStepKeys hold plans of their own, which are linted as steps in their
+// own right.
+var nestedStepKeys = []string{"on_success", "on_failure", "on_error", "on_abort", "ensure"}
+
+// testPresenceOfFiles reports the files of set_pipeline and load_var steps
+// that are in resources not present when the step runs, or that don't exist
+// in the resources given in the resource map.
+func testPresenceOfFiles(
+	sc scope,
+	resourceMap map[string]string,
+	s step,
+	jobName string,
+	pipelinePath string,
+) []Finding {
+	if s.config.TaskConfigPath == "" {
+		return []Finding{{
+			Rule:         RuleStepFiles,
+			Kind:         "files",
+			PipelinePath: pipelinePath,
+			JobName:      jobName,
+			TaskName:     s.config.Name(),
+			Position:     s.position(pipelinePath, stepType(s)),
+			Detail:       "Step is missing a file",
+		}}
+	}
+
+	present := map[string]bool{}
+	for _, resources := range [][]string{sc.resources, sc.uncertain, sc.siblings} {
+		for _, resource := range resources {
+			present[resource] = true
+		}
+	}
+
+	var missingResources, missingFiles []string
+	for _, path := range append([]string{s.config.TaskConfigPath}, s.config.VarFiles...) {
+		root := artifactRoot(path)
+		if !present[root] {
+			missingResources = appendUnique(missingResources, root)
+			continue
+		}
+
+		resolved, ok := resourcePath(resourceMap, path)
+		if !ok {
+			continue
+		}
+
+		if _, err := os.Stat(resolved); err != nil {
+			missingFiles = append(missingFiles, path)
+		}
+	}
+
+	var findings []Finding
+
+	if len(missingResources) > 0 {
+		findings = append(findings, Finding{
+			Rule:         RuleRequiredInputs,
+			Kind:         "resources",
+			PipelinePath: pipelinePath,
+			JobName:      jobName,
+			TaskName:     s.config.Name(),
+			Position:     s.position(pipelinePath, "file"),
+			Detail:       "Step refers to files in resources that are not present",
+			Missing:      missingResources,
+		})
+	}
+
+	if len(missingFiles) > 0 {
+		findings = append(findings, Finding{
+			Rule:         RuleStepFiles,
+			Kind:         "files",
+			PipelinePath: pipelinePath,
+			JobName:      jobName,
+			TaskName:     s.config.Name(),
+			Position:     s.position(pipelinePath, "file"),
+			Detail:       "Step refers to files that do not exist",
+			Missing:      missingFiles,
+		})
+	}
+
+	return findings
+}
+
+// testDefinitionOfLocalVars reports references to local vars that no earlier
+// load_var step or enclosing across sets.
+func testDefinitionOfLocalVars(
+	sc scope,
+	s step,
+	jobName string,
+	pipelinePath string,
+) []Finding {
+	defined := map[string]bool{}
+	for _, name := range sc.vars {
+		defined[name] = true
+	}
+
+	var missing []string
+	var firstMissing *yaml.Node
+	for _, node := range stepScalars(s.node) {
+		for _, token := range tokenizeVars(node.Value) {
+			if token.ref.source != "." || defined[token.ref.path] {
+				continue
+			}
+
+			if firstMissing == nil {
+				firstMissing = node
+			}
+			missing = appendUnique(missing, token.ref.path)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+
+	return []Finding{{
+		Rule:         RuleLocalVars,
+		Kind:         "vars",
+		PipelinePath: pipelinePath,
+		JobName:      jobName,
+		TaskName:     s.config.Name(),
+		Position:     nodePosition(pipelinePath, firstMissing),
+		Detail:       "Step refers to local vars that are not set",
+		Missing:      missing,
+	}}
+}
+
+// stepScalars returns the scalar values of a step, leaving out its hooks.
+func stepScalars(stepNode *yaml.Node) []*yaml.Node {
+	var scalars []*yaml.Node
+
+	var collect func(node *yaml.Node)
+	collect = func(node *yaml.Node) {
+		node = resolveNode(node)
+		if node == nil {
+			return
+		}
+
+		switch node.Kind {
+		case yaml.ScalarNode:
+			scalars = append(scalars, node)
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				collect(item)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				collect(node.Content[i+1])
+			}
+		}
+	}
+
+	for _, key := range mappingKeys(stepNode) {
+		if !contains(nestedStepKeys, key.Value) {
+			collect(mappingValue(stepNode, key.Value))
+		}
+	}
+
+	return scalars
+}
+
+// stepType returns the key that determines what kind of step s is.
+func stepType(s step) string {
+	switch {
+	case s.config.SetPipeline != "":
+		return "set_pipeline"
+	case s.config.LoadVar != "":
+		return "load_var"
+	case s.config.Get != "":
+		return "get"
+	case s.config.Put != "":
+		return "put"
+	}
+
+	return "task"
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func appendUnique(values []string, value string) []string {
+	if contains(values, value) {
+		return values
+	}
+
+	return append(values, value)
+}
@@ -0,0 +1,159 @@
+package testpipe
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var suppressionRegexp = regexp.MustCompile(`^#\s*testpipe:ignore\s+(.+)$`)
+
+// suppression is a `# testpipe:ignore rule...` comment on a job or step,
+// which keeps the findings of those rules for it from being reported.
+type suppression struct {
+	rules    []string
+	node     *yaml.Node
+	position Position
+
+	// jobName is set for suppressions on a job, which cover the findings of
+	// all of the job's steps.
+	jobName string
+
+	// used are the rules that have suppressed a finding.
+	used map[string]bool
+}
+
+// suppressionsOf returns the suppressions in the comments on a job or step:
+// those on its mapping and on its keys and scalar values, but not on the
+// steps or other nodes nested in it.
+func suppressionsOf(node *yaml.Node, jobName string, file string) []*suppression {
+	node = resolveNode(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	commented := []*yaml.Node{node}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		commented = append(commented, node.Content[i])
+		if node.Content[i+1].Kind == yaml.ScalarNode {
+			commented = append(commented, node.Content[i+1])
+		}
+	}
+
+	var suppressions []*suppression
+	for _, n := range commented {
+		for _, comment := range []string{n.HeadComment, n.LineComment} {
+			for _, line := range strings.Split(comment, "\n") {
+				match := suppressionRegexp.FindStringSubmatch(strings.TrimSpace(line))
+				if match == nil {
+					continue
+				}
+
+				suppressions = append(suppressions, &suppression{
+					rules: strings.FieldsFunc(match[1], func(r rune) bool {
+						return r == ',' || r == ' ' || r == '\t'
+					}),
+					node:     node,
+					position: nodePosition(file, n),
+					jobName:  jobName,
+					used:     map[string]bool{},
+				})
+			}
+		}
+	}
+
+	return suppressions
+}
+
+// suppresses reports whether the suppression covers a finding of ctx. Job
+// suppressions cover every finding of the job, and step suppressions those
+// of the step along with those found by checking its job or the pipeline
+// that are within the step. The findings of other steps, even those nested
+// in the step, are left to their own suppressions.
+func (s *suppression) suppresses(ctx *Context, f Finding) bool {
+	if !contains(s.rules, f.Rule) {
+		return false
+	}
+
+	switch {
+	case s.jobName != "":
+		return f.JobName == s.jobName
+	case ctx.node == s.node:
+		return true
+	case ctx.isStep():
+		return false
+	}
+
+	return f.File == ctx.PipelinePath && f.Line >= s.node.Line && f.Line <= lastLine(s.node)
+}
+
+// lastLine returns the last line a node's content starts on.
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		if l := lastLine(child); l > line {
+			line = l
+		}
+	}
+
+	return line
+}
+
+// suppress returns the findings that no suppression covers, recording
+// which suppressions were used.
+func suppress(ctx *Context, findings []Finding) []Finding {
+	var kept []Finding
+
+FINDINGS:
+	for _, f := range findings {
+		for _, s := range ctx.suppressions {
+			if s.suppresses(ctx, f) {
+				s.used[f.Rule] = true
+				continue FINDINGS
+			}
+		}
+
+		kept = append(kept, f)
+	}
+
+	return kept
+}
+
+// testUsageOfSuppressions reports the rules in suppressions that suppressed
+// nothing, leaving out rules that are disabled.
+func testUsageOfSuppressions(
+	suppressions []*suppression,
+	rules map[string]RuleConfig,
+	pipelinePath string,
+) []Finding {
+	var findings []Finding
+
+	for _, s := range suppressions {
+		var unused []string
+		for _, rule := range s.rules {
+			if !s.used[rule] && !rules[rule].Disabled {
+				unused = appendUnique(unused, rule)
+			}
+		}
+
+		if len(unused) == 0 {
+			continue
+		}
+
+		sort.Strings(unused)
+
+		findings = append(findings, Finding{
+			Rule:         RuleUnusedSuppressions,
+			Kind:         "suppressions",
+			PipelinePath: pipelinePath,
+			JobName:      s.jobName,
+			Position:     s.position,
+			Detail:       "Suppression comment no longer matches any finding",
+			Extras:       unused,
+		})
+	}
+
+	return findings
+}
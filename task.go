@@ -0,0 +1,287 @@
+package testpipe
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/concourse/atc"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var validTaskKeys = []string{
+	"platform",
+	"tags",
+	"rootfs_uri",
+	"image_resource",
+	"image",
+	"params",
+	"run",
+	"inputs",
+	"outputs",
+	"caches",
+	"container_limits",
+}
+
+var validTaskRunKeys = []string{"path", "args", "dir", "user"}
+
+// taskValidator checks a task config on its own, without the pipeline step
+// that runs it.
+type taskValidator struct {
+	// standalone tasks must say what platform and image they run on, which
+	// the pipeline step running a task may otherwise provide.
+	standalone bool
+}
+
+// LintTask lints a task file on its own, as with fly execute, with the rules
+// configured in config. An error is returned only when the file itself cannot
+// be read or the config is invalid.
+func LintTask(path string, config Config) ([]Finding, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	taskConfig, node, err := parseTask(path)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := configureFindings((taskValidator{standalone: true}).validate(taskConfig, node, path), config.Rules)
+	for i := range findings {
+		findings[i].PipelinePath = path
+		findings[i].TaskName = filepath.Base(path)
+	}
+
+	return findings, nil
+}
+
+// hasRunPath reports whether the task has a path to run, and where its run
+// key is.
+func (v taskValidator) hasRunPath(
+	config *atc.TaskConfig,
+	node *yaml.Node,
+	file string,
+) (bool, Position) {
+	return config.Run.Path != "", keyPosition(file, node, "run")
+}
+
+// validate returns the findings for a task config parsed from node. The
+// findings have no pipeline, job or task set.
+func (v taskValidator) validate(
+	config *atc.TaskConfig,
+	node *yaml.Node,
+	file string,
+) []Finding {
+	var findings []Finding
+
+	if v.standalone {
+		if mappingKey(node, "platform") == nil {
+			findings = append(findings, Finding{
+				Rule:     RuleTaskDefinition,
+				Kind:     "keys",
+				Position: nodePosition(file, resolveNode(node)),
+				Detail:   "Task is missing a platform",
+				Missing:  []string{"platform"},
+			})
+		}
+
+		if mappingKey(node, "image_resource") == nil && mappingKey(node, "rootfs_uri") == nil && mappingKey(node, "image") == nil {
+			findings = append(findings, Finding{
+				Rule:     RuleTaskDefinition,
+				Kind:     "keys",
+				Position: nodePosition(file, resolveNode(node)),
+				Detail:   "Task is missing an image",
+				Missing:  []string{"image_resource"},
+			})
+		}
+	}
+
+	if ok, pos := v.hasRunPath(config, node, file); !ok {
+		findings = append(findings, Finding{
+			Rule:     RuleTaskDefinition,
+			Kind:     "keys",
+			Position: pos,
+			Detail:   "Task is missing a path to run",
+			Missing:  []string{"run.path"},
+		})
+	}
+
+	findings = append(findings, testValidityOfTaskKeys(node, file)...)
+	findings = append(findings, testUniquenessOfArtifacts(config, node, file)...)
+
+	return findings
+}
+
+// testValidityOfTask reports the problems the validator finds with a task run
+// by a pipeline.
+func testValidityOfTask(
+	task *atc.PlanConfig,
+	tasks *TaskCache,
+	resourceMap map[string]string,
+	s step,
+	jobName string,
+	pipelinePath string,
+) []Finding {
+	node, file := taskSource(tasks, resourceMap, s, pipelinePath)
+
+	findings := taskValidator{}.validate(task.TaskConfig, node, file)
+	for i := range findings {
+		findings[i].PipelinePath = pipelinePath
+		findings[i].JobName = jobName
+		findings[i].TaskName = task.Name()
+	}
+
+	return findings
+}
+
+// taskSource returns the node a task step's config was parsed from, and the
+// file it is in.
+func taskSource(
+	tasks *TaskCache,
+	resourceMap map[string]string,
+	s step,
+	pipelinePath string,
+) (*yaml.Node, string) {
+	if s.config.TaskConfigPath == "" {
+		return mappingValue(s.node, "config"), pipelinePath
+	}
+
+	path, err := taskPath(resourceMap, s.config.TaskConfigPath)
+	if err != nil {
+		return nil, pipelinePath
+	}
+
+	_, node, _ := tasks.load(path)
+
+	return node, path
+}
+
+func testValidityOfTaskKeys(node *yaml.Node, file string) []Finding {
+	var findings []Finding
+
+	for _, check := range []struct {
+		node  *yaml.Node
+		valid []string
+		what  string
+	}{
+		{node, validTaskKeys, "task"},
+		{mappingValue(node, "run"), validTaskRunKeys, "run"},
+	} {
+		var extras []string
+		var firstExtra *yaml.Node
+		for _, key := range mappingKeys(check.node) {
+			if contains(check.valid, key.Value) {
+				continue
+			}
+
+			if firstExtra == nil {
+				firstExtra = key
+			}
+			extras = append(extras, key.Value)
+		}
+
+		if len(extras) > 0 {
+			findings = append(findings, Finding{
+				Rule:     RuleTaskKeys,
+				Kind:     "keys",
+				Position: nodePosition(file, firstExtra),
+				Detail:   fmt.Sprintf("Invalid keys passed to %s", check.what),
+				Extras:   extras,
+			})
+		}
+	}
+
+	return findings
+}
+
+// testUniquenessOfArtifacts reports inputs or outputs that share a name, and
+// artifacts that would be placed at the same path, including an input and an
+// output of the same name that are given no other path.
+func testUniquenessOfArtifacts(
+	config *atc.TaskConfig,
+	node *yaml.Node,
+	file string,
+) []Finding {
+	var findings []Finding
+
+	inputNames := make([]string, len(config.Inputs))
+	paths := map[string]map[string]bool{}
+
+	// Artifacts sharing a name are reported on their own, so paths are only
+	// shared between artifacts of different names or kinds.
+	addPath := func(kind, name, path string) {
+		if path == "" {
+			path = name
+		}
+		path = filepath.Clean(path)
+
+		if paths[path] == nil {
+			paths[path] = map[string]bool{}
+		}
+		paths[path][kind+"/"+name] = true
+	}
+
+	for i, input := range config.Inputs {
+		inputNames[i] = input.Name
+		addPath("inputs", input.Name, input.Path)
+	}
+
+	outputNames := make([]string, len(config.Outputs))
+	for i, output := range config.Outputs {
+		outputNames[i] = output.Name
+		addPath("outputs", output.Name, output.Path)
+	}
+
+	for _, artifacts := range []struct {
+		key   string
+		names []string
+	}{
+		{"inputs", inputNames},
+		{"outputs", outputNames},
+	} {
+		names := map[string]bool{}
+		var duplicates []string
+		for _, name := range artifacts.names {
+			if names[name] {
+				duplicates = appendUnique(duplicates, name)
+			}
+			names[name] = true
+		}
+
+		if len(duplicates) > 0 {
+			findings = append(findings, Finding{
+				Rule:     RuleTaskArtifacts,
+				Kind:     artifacts.key,
+				Position: keyPosition(file, node, artifacts.key),
+				Detail:   fmt.Sprintf("Task has %s with the same name", artifacts.key),
+				Extras:   duplicates,
+			})
+		}
+	}
+
+	var shared []string
+	for path, names := range paths {
+		if len(names) > 1 {
+			shared = append(shared, path)
+		}
+	}
+
+	if len(shared) > 0 {
+		sort.Strings(shared)
+
+		pos := keyPosition(file, node, "outputs")
+		if mappingKey(node, "outputs") == nil {
+			pos = keyPosition(file, node, "inputs")
+		}
+
+		findings = append(findings, Finding{
+			Rule:     RuleTaskArtifacts,
+			Kind:     "paths",
+			Position: pos,
+			Detail:   "Task has artifacts with the same path",
+			Extras:   shared,
+		})
+	}
+
+	return findings
+}
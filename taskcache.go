@@ -0,0 +1,53 @@
+package testpipe
+
+import (
+	"sync"
+
+	"github.com/concourse/atc"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// TaskCache holds the task files parsed while linting so that each is only
+// read once, however many tasks and pipelines use it. It is safe for
+// concurrent use by pipelines linted in parallel.
+type TaskCache struct {
+	mu    sync.Mutex
+	tasks map[string]*cachedTask
+}
+
+type cachedTask struct {
+	once   sync.Once
+	config *atc.TaskConfig
+	node   *yaml.Node
+	err    error
+}
+
+// NewTaskCache returns an empty cache, to be given to each pipeline linted
+// with TestPipe.SetTaskCache.
+func NewTaskCache() *TaskCache {
+	return &TaskCache{
+		tasks: map[string]*cachedTask{},
+	}
+}
+
+// load returns the parsed task file at path. A nil cache parses the file
+// every time.
+func (c *TaskCache) load(path string) (*atc.TaskConfig, *yaml.Node, error) {
+	if c == nil {
+		return parseTask(path)
+	}
+
+	c.mu.Lock()
+	task, ok := c.tasks[path]
+	if !ok {
+		task = &cachedTask{}
+		c.tasks[path] = task
+	}
+	c.mu.Unlock()
+
+	task.once.Do(func() {
+		task.config, task.node, task.err = parseTask(path)
+	})
+
+	return task.config, task.node, task.err
+}
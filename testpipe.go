@@ -9,20 +9,97 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/concourse/atc"
-	yaml "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	ResourceMap map[string]string `yaml:"resource_map"`
+
+	// Vars are interpolated into ((var)) and {{var}} references. When nil,
+	// along with SharedVars, references are left alone and never reported as
+	// undefined.
+	Vars map[string]interface{} `yaml:"vars"`
+
+	// SharedVars are interpolated like Vars, which take precedence, but are
+	// also given to other pipelines, so those the pipeline doesn't use are
+	// not reported.
+	SharedVars map[string]interface{} `yaml:"-"`
+
+	// Workspace is a directory of checked out repositories, which the git
+	// resources of a pipeline are mapped to when they are missing from
+	// ResourceMap.
+	Workspace string `yaml:"workspace"`
+
+	// Rules configures rules by ID. Rules that aren't configured run with
+	// their default severity.
+	Rules map[string]RuleConfig `yaml:"rules"`
 }
 
 type TestPipe struct {
-	path   string
-	config Config
-	tmpl   *template.Template
+	path          string
+	config        Config
+	subjects      []Subject
+	graph         Graph
+	fileResources []string
+	resourceMap   map[string]string
+	tasks         *TaskCache
+	varsUsed      []string
+}
+
+// Subject is a job step that was linted by Run.
+type Subject struct {
+	PipelinePath string
+	JobName      string
+	TaskName     string
+}
+
+// Rule IDs identify the check that produced a Finding.
+const (
+	RuleParamsParity   = "params-parity"
+	RuleRequiredInputs = "required-inputs"
+	RuleTaskDefinition = "task-definition"
+	RuleStepKeys       = "step-keys"
+
+	RuleUndeclaredResource  = "undeclared-resource"
+	RuleUnknownResourceType = "unknown-resource-type"
+	RuleUnusedResource      = "unused-resource"
+	RuleUnusedResourceType  = "unused-resource-type"
+	RulePassedConstraints   = "passed-constraints"
+	RuleHookInputs          = "hook-inputs"
+	RuleParallelInputs      = "parallel-inputs"
+
+	RuleStepDefinition   = "step-definition"
+	RuleStepFiles        = "step-files"
+	RuleLocalVars        = "local-vars"
+	RulePipelineVars     = "pipeline-vars"
+	RuleUndefinedVars    = "undefined-vars"
+	RuleVarInterpolation = "var-interpolation"
+	RuleUnusedVars       = "unused-vars"
+	RuleTaskKeys         = "task-keys"
+	RuleTaskArtifacts    = "task-artifacts"
+
+	RuleUnusedSuppressions = "unused-suppressions"
+)
+
+// Finding is a single violation found while linting a pipeline.
+type Finding struct {
+	Rule         string   `json:"rule"`
+	Severity     Severity `json:"severity"`
+	Kind         string   `json:"kind"`
+	PipelinePath string   `json:"pipeline"`
+	JobName      string   `json:"job"`
+	TaskName     string   `json:"task"`
+	Position
+	Detail  string   `json:"message"`
+	Extras  []string `json:"extras"`
+	Missing []string `json:"missing"`
+
+	// fix is set for findings that Fix can fix.
+	fix *paramsFix
 }
 
 type TemplateData struct {
@@ -52,94 +129,378 @@ const outputTemplate = `
   {{end -}}
 `
 
+var outputTmpl = template.Must(template.New("output").Parse(outputTemplate))
+
 func New(path string, config Config) *TestPipe {
 	return &TestPipe{
 		path:   path,
 		config: config,
-		tmpl:   template.Must(template.New("output").Parse(outputTemplate)),
 	}
 }
 
+// String renders the finding in the human-readable output format.
+func (f Finding) String() string {
+	buf := &bytes.Buffer{}
+	data := TemplateData{
+		Type:         f.Kind,
+		PipelinePath: f.PipelinePath,
+		JobName:      f.JobName,
+		TaskName:     f.TaskName,
+		Extras:       f.Extras,
+		Missing:      f.Missing,
+	}
+	if err := outputTmpl.Execute(buf, data); err != nil {
+		log.Fatalf("failed to execute template: %s", err)
+	}
+
+	if f.Severity != "" && f.Severity != SeverityError {
+		return fmt.Sprintf("%s: %s: %s", f.Severity, f.Detail, buf.String())
+	}
+
+	return fmt.Sprintf("%s: %s", f.Detail, buf.String())
+}
+
+// SetTaskCache shares a cache of task files with other pipelines, so that
+// each file is only parsed once. Without one, each call to Run parses the
+// task files of the pipeline on its own.
+func (t *TestPipe) SetTaskCache(tasks *TaskCache) {
+	t.tasks = tasks
+}
+
 var placeholderRegexp = regexp.MustCompile("{{([a-zA-Z0-9-_]+)}}")
 
-func (t *TestPipe) Run() error {
+// Run lints the pipeline and returns every finding across all of its jobs
+// and tasks. An error is returned only when the pipeline itself cannot be
+// read.
+func (t *TestPipe) Run() ([]Finding, error) {
+	return t.run(map[string]bool{})
+}
+
+// run lints the pipeline along with the pipelines its set_pipeline steps
+// set, skipping those already in linted.
+func (t *TestPipe) run(linted map[string]bool) ([]Finding, error) {
+	linted[pipelineKey(t.path)] = true
+
+	if err := t.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	taskCache := t.tasks
+	if taskCache == nil {
+		taskCache = NewTaskCache()
+	}
+
 	configBytes, err := ioutil.ReadFile(t.path)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	vars := t.config.Vars
+	if t.config.SharedVars != nil {
+		vars = map[string]interface{}{}
+		for k, v := range t.config.SharedVars {
+			vars[k] = v
+		}
+		for k, v := range t.config.Vars {
+			vars[k] = v
+		}
+	}
+
+	cleanConfigBytes, varUses := interpolatePlaceholders(configBytes, vars, t.path)
+
+	var root yaml.Node
+	err = yaml.Unmarshal(cleanConfigBytes, &root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pipeline at %s: %s", t.path, err)
 	}
 
-	cleanConfigBytes := placeholderRegexp.ReplaceAll(configBytes, []byte("true"))
+	interpolated := map[*yaml.Node]bool{}
+	varUses = append(varUses, interpolateVars(&root, vars, t.path, interpolated)...)
+	sortVarUses(varUses)
 
 	var config atc.Config
-	err = yaml.Unmarshal(cleanConfigBytes, &config)
+	err = decodeNode(&root, &config)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal pipeline at %s: %s", t.path, err)
+		return nil, fmt.Errorf("failed to unmarshal pipeline at %s: %s", t.path, err)
+	}
+
+	jobNodes := sequenceItems(mappingValue(&root, "jobs"))
+
+	t.resourceMap = t.config.ResourceMap
+	if t.config.Workspace != "" {
+		t.resourceMap = workspaceResourceMap(config, t.config.Workspace, t.config.ResourceMap)
 	}
 
-	for _, job := range config.Jobs {
-		var resources []string
-		var tasks []atc.PlanConfig
+	var findings []Finding
+	t.subjects = nil
+
+	// Every job is walked before any rule is checked, so that each job is
+	// checked ahead of its steps, once the steps that can't be decoded are
+	// known.
+	var contexts []*Context
+	var suppressions []*suppression
+
+	jobSteps := map[string][]step{}
+	var tasks []atc.PlanConfig
+	var taskFiles []string
+	unloadedTasks := false
+
+	for i := range config.Jobs {
+		job := &config.Jobs[i]
+		jobNode := itemAt(jobNodes, i)
+		planNode := mappingValue(jobNode, "plan")
+
+		suppressions = append(suppressions, suppressionsOf(jobNode, job.Name, t.path)...)
+
+		jobContext := &Context{
+			PipelinePath: t.path,
+			Pipeline:     config,
+			Job:          job,
+			node:         jobNode,
+		}
+		contexts = append(contexts, jobContext)
 
-		resourceMap := make(map[string]string, len(t.config.ResourceMap))
-		for k, v := range t.config.ResourceMap {
+		resourceMap := make(map[string]string, len(t.resourceMap))
+		for k, v := range t.resourceMap {
 			resourceMap[k] = v
 		}
 
-		for _, planConfig := range flattenedPlan(&job.Plan) {
+		// outputs is also called for steps that have yet to be visited, to
+		// find out what parallel siblings produce, so it leaves the resource
+		// map alone.
+		outputs := func(s step) []string {
+			planConfig := s.config
+
 			switch {
 			case planConfig.Get != "":
-				resources = append(resources, planConfig.Get)
-
 				if planConfig.Resource != "" {
-					resources = append(resources, planConfig.Resource)
-					origPath := resourceMap[planConfig.Resource]
-					resourceMap[planConfig.Get] = origPath
+					return []string{planConfig.Get, planConfig.Resource}
 				}
 
+				return []string{planConfig.Get}
+
 			case planConfig.Put != "":
-				resources = append(resources, planConfig.Put)
+				return []string{planConfig.Put}
 
 			case planConfig.Task != "":
-				canonicalTask, err := flattenTask(resourceMap, &planConfig, job.Name)
+				canonicalTask, err := flattenTask(taskCache, resourceMap, s, job.Name, t.path)
 				if err != nil {
-					return err
+					return nil
 				}
 
-				err = testParityOfParams(canonicalTask, job.Name, t.path, t.tmpl)
-				if err != nil {
-					return err
-				}
+				return taskOutputs(canonicalTask)
+			}
 
-				err = testPresenceOfRequiredResources(resources, canonicalTask, job.Name, t.path, t.tmpl)
-				if err != nil {
-					return err
-				}
+			return nil
+		}
+
+		visit := func(s step, sc scope) []string {
+			planConfig := s.config
+
+			t.subjects = append(t.subjects, Subject{
+				PipelinePath: t.path,
+				JobName:      job.Name,
+				TaskName:     planConfig.Name(),
+			})
+
+			jobSteps[job.Name] = append(jobSteps[job.Name], s)
+			suppressions = append(suppressions, suppressionsOf(s.node, "", t.path)...)
+
+			// Gets that rename a resource later in the plan change the
+			// resource map, which the step must not see.
+			stepResourceMap := make(map[string]string, len(resourceMap))
+			for k, v := range resourceMap {
+				stepResourceMap[k] = v
+			}
 
-				tasks = append(tasks, *canonicalTask)
+			ctx := &Context{
+				PipelinePath: t.path,
+				Pipeline:     config,
+				Job:          job,
+				Step:         &planConfig.PlanConfig,
+				Resources:    sc.resources,
+				node:         s.node,
+				step:         s,
+				scope:        sc,
+				resourceMap:  stepResourceMap,
+				tasks:        taskCache,
+				interpolated: interpolated,
+			}
+
+			contexts = append(contexts, ctx)
 
-				if canonicalTask.TaskConfig.Outputs != nil {
-					for i := range canonicalTask.TaskConfig.Outputs {
-						resources = append(resources, canonicalTask.TaskConfig.Outputs[i].Name)
+			if planConfig.Task != "" {
+				ctx.task, ctx.taskErr = flattenTask(taskCache, stepResourceMap, s, job.Name, t.path)
+				if ctx.task != nil {
+					ctx.Step = ctx.task
+				}
+			}
+
+			if ctx.task == nil {
+				if planConfig.Task != "" {
+					// The image of a task that can't be loaded is known only
+					// when it is given inline.
+					if planConfig.TaskConfigPath == "" && planConfig.TaskConfig != nil {
+						tasks = append(tasks, planConfig.PlanConfig)
+					} else {
+						unloadedTasks = true
 					}
+
+					return nil
 				}
 
-				for _, v := range canonicalTask.OutputMapping {
-					resources = append(resources, v)
+				// A get that renames a resource loads files from the
+				// resource it gets under the new name.
+				if planConfig.Get != "" && planConfig.Resource != "" {
+					resourceMap[planConfig.Get] = resourceMap[planConfig.Resource]
 				}
+
+				return outputs(s)
+			}
+
+			tasks = append(tasks, *ctx.task)
+
+			if planConfig.TaskConfigPath != "" {
+				path, _ := taskPath(stepResourceMap, planConfig.TaskConfigPath)
+				taskFiles = appendUnique(taskFiles, path)
+			}
+
+			return taskOutputs(ctx.task)
+		}
+
+		walker := planWalker{
+			visit:   visit,
+			outputs: outputs,
+			invalid: func(node *yaml.Node, err error) {
+				suppressions = append(suppressions, suppressionsOf(node, "", t.path)...)
+				jobContext.invalid = append(jobContext.invalid, invalidStep{node: node, err: err})
+			},
+		}
+
+		// Only the outputs of the first step are assumed to exist when the
+		// job fails or is aborted; anything produced later in the plan may
+		// never have been.
+		var completed, guaranteed scope
+		for i, item := range sequenceItems(planNode) {
+			completed = walker.walkStep(item, completed)
+			if i == 0 {
+				guaranteed = completed
+			}
+		}
+
+		for _, key := range jobHookKeys {
+			hookNode := mappingValue(jobNode, key)
+			if hookNode == nil {
+				continue
+			}
+
+			if key == "on_success" {
+				walker.walkStep(hookNode, completed)
+			} else {
+				walker.walkStep(hookNode, scope{
+					resources: guaranteed.resources,
+					uncertain: withResources(completed.uncertain, completed.resources[len(guaranteed.resources):]...),
+					vars:      guaranteed.vars,
+				})
 			}
 		}
 	}
 
-	return nil
+	if vars != nil {
+		for _, path := range taskFiles {
+			varUses = append(varUses, taskVarUses(taskCache, path, vars)...)
+		}
+	}
+
+	t.varsUsed = varNames(varUses)
+	t.graph = buildGraph(config, jobSteps)
+	t.fileResources = fileResources(jobSteps)
+
+	pipelineContext := &Context{
+		PipelinePath:  t.path,
+		Pipeline:      config,
+		node:          &root,
+		jobSteps:      jobSteps,
+		allTasks:      tasks,
+		unloadedTasks: unloadedTasks,
+		vars:          vars,
+		ownVars:       t.config.Vars,
+		varUses:       varUses,
+		rules:         t.config.Rules,
+	}
+
+	// The pipeline is checked ahead of its jobs by the rules that check what
+	// it declares, and after them by every other rule.
+	contexts = append([]*Context{pipelineContext}, contexts...)
+	contexts = append(contexts, pipelineContext)
+
+	var rules []Rule
+	for _, rule := range Rules() {
+		if rule.ID() != RuleUnusedSuppressions && !t.config.Rules[rule.ID()].Disabled {
+			rules = append(rules, rule)
+		}
+	}
+
+	for i, ctx := range contexts {
+		ctx.suppressions = suppressions
+
+		var childFindings []Finding
+		if ctx.isStep() && ctx.step.config.SetPipeline != "" {
+			childFindings, ctx.childErr = t.lintChildPipeline(taskCache, ctx.resourceMap, ctx.step, linted)
+		}
+
+		for _, rule := range rules {
+			if ctx.isPipeline() && checksDeclarations(rule) != (i == 0) {
+				continue
+			}
+
+			findings = append(findings, ctx.check(rule, t.config.Rules[rule.ID()])...)
+		}
+
+		findings = append(findings, childFindings...)
+	}
+
+	if config := t.config.Rules[RuleUnusedSuppressions]; !config.Disabled {
+		findings = append(findings, pipelineContext.check(unusedSuppressions, config)...)
+	}
+
+	return findings, nil
 }
 
-func testPresenceOfRequiredResources(
-	resources []string,
-	task *atc.PlanConfig,
-	jobName string,
-	pipelinePath string,
-	tmpl *template.Template,
-) error {
+// Subjects returns every job step linted by the most recent call to Run,
+// including those of pipelines set by set_pipeline steps, whether or not
+// any findings were reported for it.
+func (t *TestPipe) Subjects() []Subject {
+	return t.subjects
+}
+
+// VarsUsed returns the names of the vars given in Config that the pipeline
+// and the task files it loads referred to in the most recent call to Run.
+func (t *TestPipe) VarsUsed() []string {
+	return t.varsUsed
+}
+
+// Graph returns how resources flow between the jobs of the pipeline, as
+// found by the most recent call to Run.
+func (t *TestPipe) Graph() Graph {
+	return t.graph
+}
+
+// ResourceMap returns the resource map used by the most recent call to Run,
+// which includes the git resources found in Config.Workspace.
+func (t *TestPipe) ResourceMap() map[string]string {
+	return t.resourceMap
+}
+
+// FileResources returns the resources the pipeline's steps load files from,
+// as found by the most recent call to Run. These are the resources that need
+// a path in Config.ResourceMap.
+func (t *TestPipe) FileResources() []string {
+	return t.fileResources
+}
+
+// missingInputs returns the inputs of task that none of resources satisfy.
+func missingInputs(resources []string, task *atc.PlanConfig) []string {
 	var missing []string
 OUTER:
 	for _, input := range task.TaskConfig.Inputs {
@@ -156,31 +517,113 @@ OUTER:
 		missing = append(missing, input.Name)
 	}
 
+	return missing
+}
+
+// testPresenceOfRequiredResources reports inputs that are missing from the
+// scope, and inputs that are only found among uncertain or sibling
+// resources, which may not be present when the task runs.
+func testPresenceOfRequiredResources(
+	sc scope,
+	task *atc.PlanConfig,
+	s step,
+	jobName string,
+	pipelinePath string,
+) []Finding {
+	var findings []Finding
+
+	all := withResources(sc.resources, sc.uncertain...)
+	all = withResources(all, sc.siblings...)
+
+	missing := missingInputs(all, task)
 	if len(missing) > 0 {
-		buf := &bytes.Buffer{}
-		data := TemplateData{
-			Type:         "resources",
+		findings = append(findings, Finding{
+			Rule:         RuleRequiredInputs,
+			Kind:         "resources",
 			PipelinePath: pipelinePath,
 			JobName:      jobName,
 			TaskName:     task.Name(),
+			Position:     s.position(pipelinePath, "task"),
+			Detail:       "Task invocation is missing resources",
 			Missing:      missing,
+		})
+	}
+
+	fromSiblings := onlyIn(withResources(sc.resources, sc.uncertain...), all, task)
+	if len(fromSiblings) > 0 {
+		findings = append(findings, Finding{
+			Rule:         RuleParallelInputs,
+			Kind:         "resources",
+			PipelinePath: pipelinePath,
+			JobName:      jobName,
+			TaskName:     task.Name(),
+			Position:     s.position(pipelinePath, "task"),
+			Detail:       "Task invocation relies on resources produced by a parallel step",
+			Missing:      fromSiblings,
+		})
+	}
+
+	fromUncertain := onlyIn(sc.resources, withResources(sc.resources, sc.uncertain...), task)
+	if len(fromUncertain) > 0 {
+		findings = append(findings, Finding{
+			Rule:         RuleHookInputs,
+			Kind:         "resources",
+			PipelinePath: pipelinePath,
+			JobName:      jobName,
+			TaskName:     task.Name(),
+			Position:     s.position(pipelinePath, "task"),
+			Detail:       "Task invocation may be missing resources produced mid-plan",
+			Missing:      fromUncertain,
+		})
+	}
+
+	return findings
+}
+
+// onlyIn returns the inputs of task that are missing from narrow but
+// satisfied by wide.
+func onlyIn(narrow []string, wide []string, task *atc.PlanConfig) []string {
+	stillMissing := map[string]bool{}
+	for _, name := range missingInputs(wide, task) {
+		stillMissing[name] = true
+	}
+
+	var inputs []string
+	for _, name := range missingInputs(narrow, task) {
+		if !stillMissing[name] {
+			inputs = append(inputs, name)
 		}
-		if err := tmpl.Execute(buf, data); err != nil {
-			log.Fatalf("failed to execute template: %s", err)
-		}
+	}
+
+	return inputs
+}
 
-		return fmt.Errorf("Task invocation is missing resources: %s", buf.String())
+// taskOutputs returns the resources a task produces, under both their own
+// and their mapped names.
+func taskOutputs(task *atc.PlanConfig) []string {
+	var outputs []string
+	for i := range task.TaskConfig.Outputs {
+		outputs = append(outputs, task.TaskConfig.Outputs[i].Name)
 	}
 
-	return nil
+	for _, v := range task.OutputMapping {
+		outputs = append(outputs, v)
+	}
+
+	return outputs
 }
 
+// testParityOfParams reports params the task declares that the step doesn't
+// give it, and params the step gives that the task doesn't declare, unless
+// they are allowed as extras.
 func testParityOfParams(
 	task *atc.PlanConfig,
+	allowedExtras []string,
+	s step,
+	interpolated map[*yaml.Node]bool,
 	jobName string,
 	pipelinePath string,
-	tmpl *template.Template,
-) error {
+) []Finding {
 	var extras, missing []string
 
 	for k := range task.TaskConfig.Params {
@@ -190,106 +633,266 @@ func testParityOfParams(
 	}
 
 	for k := range task.Params {
-		if _, ok := task.TaskConfig.Params[k]; !ok {
+		if _, ok := task.TaskConfig.Params[k]; !ok && !contains(allowedExtras, k) {
 			extras = append(extras, k)
 		}
 	}
 
+	sort.Strings(extras)
+	sort.Strings(missing)
+
 	if len(missing) > 0 || len(extras) > 0 {
-		buf := &bytes.Buffer{}
-		data := TemplateData{
-			Type:         "params",
+		pos := s.position(pipelinePath, "task")
+		if len(extras) > 0 {
+			pos = s.position(pipelinePath, "params")
+		}
+
+		return []Finding{{
+			Rule:         RuleParamsParity,
+			Kind:         "params",
 			PipelinePath: pipelinePath,
 			JobName:      jobName,
 			TaskName:     task.Name(),
+			Position:     pos,
+			Detail:       "Params do not have parity",
 			Extras:       extras,
 			Missing:      missing,
-		}
-		if err := tmpl.Execute(buf, data); err != nil {
-			log.Fatalf("failed to execute template: %s", err)
-		}
-
-		return fmt.Errorf("Params do not have parity: %s", buf.String())
+			fix: &paramsFix{
+				step:         s.node,
+				extras:       extras,
+				missing:      missing,
+				defaults:     task.TaskConfig.Params,
+				interpolated: interpolated,
+			},
+		}}
 	}
 
 	return nil
 }
 
-func flattenedPlan(seq *atc.PlanSequence) []atc.PlanConfig {
-	var flatPlan []atc.PlanConfig
+var hookKeys = []string{"on_success", "on_failure", "on_error", "on_abort", "ensure", "timeout", "attempts", "tags", "across"}
+
+// jobHookKeys are the plans a job runs once its main plan has completed.
+var jobHookKeys = []string{"on_success", "on_failure", "ensure", "on_abort"}
+
+var validStepKeys = map[string][]string{
+	"get":          {"get", "resource", "version", "passed", "trigger", "params"},
+	"put":          {"put", "resource", "params", "get_params", "inputs", "no_get"},
+	"task":         {"task", "config", "file", "privileged", "params", "image", "input_mapping", "output_mapping", "vars", "container_limits"},
+	"set_pipeline": {"set_pipeline", "file", "vars", "var_files", "team", "instance_vars"},
+	"load_var":     {"load_var", "file", "format", "reveal"},
+	"aggregate":    {"aggregate"},
+	"in_parallel":  {"in_parallel"},
+	"do":           {"do"},
+	"try":          {"try"},
+}
+
+// testValidityOfKeys checks the steps of a plan as written, since unknown
+// keys are dropped when unmarshaling into atc.PlanConfig.
+func testValidityOfKeys(
+	plan *yaml.Node,
+	jobName string,
+	pipelinePath string,
+) []Finding {
+	var findings []Finding
+
+	for _, stepNode := range sequenceItems(plan) {
+		findings = append(findings, testValidityOfStepKeys(stepNode, jobName, pipelinePath)...)
+	}
 
-	for _, planConfig := range *seq {
-		switch {
-		case planConfig.Aggregate != nil:
-			flatPlan = append(flatPlan, flattenedPlan(planConfig.Aggregate)...)
+	return findings
+}
 
-		case planConfig.Do != nil:
-			flatPlan = append(flatPlan, flattenedPlan(planConfig.Do)...)
+func testValidityOfStepKeys(
+	stepNode *yaml.Node,
+	jobName string,
+	pipelinePath string,
+) []Finding {
+	var findings []Finding
 
-		case planConfig.Get != "", planConfig.Put != "", planConfig.Task != "":
-			flatPlan = append(flatPlan, planConfig)
+	for _, key := range []string{"aggregate", "do"} {
+		findings = append(findings, testValidityOfKeys(mappingValue(stepNode, key), jobName, pipelinePath)...)
+	}
+
+	for _, parallelStep := range parallelSteps(stepNode) {
+		findings = append(findings, testValidityOfStepKeys(parallelStep, jobName, pipelinePath)...)
+	}
+
+	for _, key := range []string{"try", "on_success", "on_failure", "on_error", "on_abort", "ensure"} {
+		if hook := mappingValue(stepNode, key); hook != nil {
+			findings = append(findings, testValidityOfStepKeys(hook, jobName, pipelinePath)...)
+		}
+	}
+
+	keys := mappingKeys(stepNode)
+
+	var stepType string
+	for _, key := range keys {
+		if _, ok := validStepKeys[key.Value]; ok {
+			stepType = key.Value
+			break
+		}
+	}
+
+	switch stepType {
+	case "get", "put", "task", "set_pipeline", "load_var":
+	default:
+		return findings
+	}
+
+	var extras []string
+	var firstExtra *yaml.Node
+KEYS:
+	for _, key := range keys {
+		for _, valid := range append(validStepKeys[stepType], hookKeys...) {
+			if key.Value == valid {
+				continue KEYS
+			}
 		}
+
+		if firstExtra == nil {
+			firstExtra = key
+		}
+		extras = append(extras, key.Value)
+	}
+
+	if len(extras) > 0 {
+		findings = append(findings, Finding{
+			Rule:         RuleStepKeys,
+			Kind:         "keys",
+			PipelinePath: pipelinePath,
+			JobName:      jobName,
+			TaskName:     mappingValue(stepNode, stepType).Value,
+			Position:     nodePosition(pipelinePath, firstExtra),
+			Detail:       fmt.Sprintf("Invalid keys passed to %s", stepType),
+			Extras:       extras,
+		})
 	}
 
-	return flatPlan
+	return findings
 }
 
 func flattenTask(
+	tasks *TaskCache,
 	resourceMap map[string]string,
-	task *atc.PlanConfig,
+	s step,
 	jobName string,
+	pipelinePath string,
 ) (*atc.PlanConfig, error) {
-	result := task
+	task := s.config.PlanConfig
+	result := &task
 
 	if task.TaskConfigPath != "" {
-		var err error
-		result, err = loadTask(resourceMap, task)
+		path, err := taskPath(resourceMap, task.TaskConfigPath)
+		if err != nil {
+			return nil, &positionError{s.position(pipelinePath, "file"), err}
+		}
+
+		result, err = loadTask(tasks, path, &task)
 		if err != nil {
-			return nil, err
+			return nil, &positionError{s.position(pipelinePath, "file"), err}
 		}
 	}
 
 	if result.TaskConfig == nil {
-		return nil, fmt.Errorf("task %s/%s is missing a definition", jobName, task.Name())
+		return nil, &positionError{
+			s.position(pipelinePath, "task"),
+			fmt.Errorf("task %s/%s is missing a definition", jobName, task.Name()),
+		}
 	}
 
-	if result.TaskConfig.Run.Path == "" {
-		return nil, fmt.Errorf("task %s/%s is missing a path", jobName, task.Name())
+	node, file := taskSource(tasks, resourceMap, s, pipelinePath)
+	if ok, runPos := (taskValidator{}).hasRunPath(result.TaskConfig, node, file); !ok {
+		return nil, &positionError{
+			runPos,
+			fmt.Errorf("task %s/%s is missing a path", jobName, task.Name()),
+		}
 	}
 
 	return result, nil
 }
 
-func loadTask(
+// taskPath resolves the path of a task file on disk through the resource map.
+func taskPath(
 	resourceMap map[string]string,
+	configPath string,
+) (string, error) {
+	if len(resourceMap) == 0 {
+		return "", fmt.Errorf("failed to load %s; no config provided", configPath)
+	}
+
+	if path, ok := resourcePath(resourceMap, configPath); ok {
+		return path, nil
+	}
+
+	return "", fmt.Errorf("failed to find path for task: %s", configPath)
+}
+
+// resourcePath resolves a path within a resource, as given to a step's file
+// key, to a path on disk through the resource map.
+func resourcePath(
+	resourceMap map[string]string,
+	path string,
+) (string, bool) {
+	resourceRoot := artifactRoot(path)
+
+	if dir, ok := resourceMap[resourceRoot]; ok && dir != "" {
+		return filepath.Join(dir, strings.Replace(path, resourceRoot, "", -1)), true
+	}
+
+	return "", false
+}
+
+// artifactRoot returns the artifact a path within a build refers to.
+func artifactRoot(path string) string {
+	return strings.Split(path, string(os.PathSeparator))[0]
+}
+
+func loadTask(
+	tasks *TaskCache,
+	path string,
 	task *atc.PlanConfig,
 ) (*atc.PlanConfig, error) {
-	if len(resourceMap) == 0 {
-		return nil, fmt.Errorf("failed to load %s; no config provided", task.TaskConfigPath)
+	taskConfig, _, err := tasks.load(path)
+	if err != nil {
+		return nil, err
 	}
 
-	resourceRoot := strings.Split(task.TaskConfigPath, string(os.PathSeparator))[0]
+	// The parsed config may be shared with other pipelines linted at the
+	// same time, so each task gets its own copy.
+	config := *taskConfig
 
-	var path string
-	if resourcePath, ok := resourceMap[resourceRoot]; ok && resourcePath != "" {
-		path = filepath.Join(resourcePath, strings.Replace(task.TaskConfigPath, resourceRoot, "", -1))
-	} else {
-		return nil, fmt.Errorf("failed to find path for task: %s", task.TaskConfigPath)
+	config.Params = make(map[string]string, len(taskConfig.Params))
+	for k, v := range taskConfig.Params {
+		config.Params[k] = v
 	}
 
+	config.Inputs = append([]atc.TaskInputConfig(nil), taskConfig.Inputs...)
+	config.Outputs = append([]atc.TaskOutputConfig(nil), taskConfig.Outputs...)
+
+	result := *task
+	result.TaskConfig = &config
+
+	return &result, nil
+}
+
+func parseTask(path string) (*atc.TaskConfig, *yaml.Node, error) {
 	bs, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open task at %s", path)
+		return nil, nil, fmt.Errorf("failed to open task at %s", path)
 	}
 
-	var taskConfig atc.TaskConfig
-	err = yaml.Unmarshal(bs, &taskConfig)
+	var root yaml.Node
+	err = yaml.Unmarshal(bs, &root)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	result := task
-	result.TaskConfig = &taskConfig
+	var taskConfig atc.TaskConfig
+	err = decodeNode(&root, &taskConfig)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return result, nil
+	return &taskConfig, &root, nil
 }
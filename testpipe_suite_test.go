@@ -0,0 +1,13 @@
+package testpipe_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTestpipe(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Testpipe Suite")
+}
@@ -0,0 +1,502 @@
+package testpipe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// varReference is a parsed ((source:path.field)) reference. Source is empty
+// for vars given to testpipe and "." for local vars.
+type varReference struct {
+	source string
+	path   string
+	fields []string
+}
+
+// name returns the reference as written, without its source.
+func (r varReference) name() string {
+	return strings.Join(append([]string{r.path}, r.fields...), ".")
+}
+
+// varToken is a ((var)) reference within some text, where start and end are
+// the byte offsets of its parentheses.
+type varToken struct {
+	start int
+	end   int
+	ref   varReference
+}
+
+// tokenizeVars returns the well-formed ((var)) references in text.
+func tokenizeVars(text string) []varToken {
+	var tokens []varToken
+
+	for i := 0; i+1 < len(text); {
+		if text[i] != '(' || text[i+1] != '(' {
+			i++
+			continue
+		}
+
+		end, ok := scanVarEnd(text, i+2)
+		if !ok {
+			i++
+			continue
+		}
+
+		ref, err := parseVarReference(text[i+2 : end])
+		if err != nil {
+			i++
+			continue
+		}
+
+		tokens = append(tokens, varToken{start: i, end: end + 2, ref: ref})
+		i = end + 2
+	}
+
+	return tokens
+}
+
+// scanVarEnd returns the offset of the )) closing a reference whose contents
+// start at from.
+func scanVarEnd(text string, from int) (int, bool) {
+	quoted := false
+	for i := from; i < len(text); i++ {
+		switch {
+		case text[i] == '"':
+			quoted = !quoted
+		case quoted:
+		case text[i] == '\n', text[i] == '(':
+			return 0, false
+		case text[i] == ')':
+			if i+1 < len(text) && text[i+1] == ')' {
+				return i, true
+			}
+			return 0, false
+		}
+	}
+
+	return 0, false
+}
+
+// parseVarReference parses the contents of a ((var)) reference, e.g.
+// source:path.field, where any segment may be double-quoted to include dots
+// or colons.
+func parseVarReference(raw string) (varReference, error) {
+	var ref varReference
+
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, ".:") {
+		ref.source = "."
+		raw = raw[2:]
+	}
+
+	var segments []string
+	var segment strings.Builder
+	quoted, sourceDone := false, ref.source != ""
+
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			segment.WriteByte(c)
+		case c == ':' && !sourceDone && len(segments) == 0:
+			ref.source = segment.String()
+			segment.Reset()
+			sourceDone = true
+		case c == '.':
+			segments = append(segments, segment.String())
+			segment.Reset()
+		case c == ' ', c == '\t':
+			return varReference{}, fmt.Errorf("invalid var reference: %s", raw)
+		default:
+			segment.WriteByte(c)
+		}
+	}
+	segments = append(segments, segment.String())
+
+	if quoted {
+		return varReference{}, fmt.Errorf("unterminated quote in var reference: %s", raw)
+	}
+
+	for _, s := range segments {
+		if s == "" {
+			return varReference{}, fmt.Errorf("invalid var reference: %s", raw)
+		}
+	}
+
+	if sourceDone && ref.source == "" {
+		return varReference{}, fmt.Errorf("invalid var reference: %s", raw)
+	}
+
+	ref.path = segments[0]
+	ref.fields = segments[1:]
+
+	return ref, nil
+}
+
+// varUse is a reference to a var given to testpipe, where it was found, and
+// whether the var has a value. A use is invalid when a map or list is
+// interpolated into a larger string, which Concourse refuses to do.
+type varUse struct {
+	ref varReference
+	Position
+	defined bool
+	invalid bool
+}
+
+// interpolatePlaceholders replaces {{name}} placeholders with their values
+// encoded as JSON, as fly does. Placeholders must be replaced before the
+// pipeline is parsed, since they aren't valid YAML; those without a value are
+// replaced with true.
+func interpolatePlaceholders(
+	configBytes []byte,
+	vars map[string]interface{},
+	file string,
+) ([]byte, []varUse) {
+	var result bytes.Buffer
+	var uses []varUse
+
+	last := 0
+	for _, match := range placeholderRegexp.FindAllSubmatchIndex(configBytes, -1) {
+		result.Write(configBytes[last:match[0]])
+		last = match[1]
+
+		ref := varReference{path: string(configBytes[match[2]:match[3]])}
+		value, ok := lookupVar(vars, ref)
+
+		line, column := offsetPosition(configBytes, match[0])
+		uses = append(uses, varUse{
+			ref:      ref,
+			Position: Position{File: file, Line: line, Column: column},
+			defined:  ok,
+		})
+
+		bs, err := json.Marshal(value)
+		if !ok || err != nil {
+			result.WriteString("true")
+			continue
+		}
+
+		result.Write(bs)
+	}
+	result.Write(configBytes[last:])
+
+	return result.Bytes(), uses
+}
+
+// interpolateVars replaces ((name)) references in the values of a parsed
+// pipeline. A value that is nothing but a reference takes on the var's value
+// as is, which may be a map or a list; the nodes it is replaced with are
+// added to interpolated, since they are not in the file. References to local
+// vars and to named var sources are left alone.
+func interpolateVars(
+	node *yaml.Node,
+	vars map[string]interface{},
+	file string,
+	interpolated map[*yaml.Node]bool,
+) []varUse {
+	if node == nil {
+		return nil
+	}
+
+	var uses []varUse
+
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, item := range node.Content {
+			uses = append(uses, interpolateVars(item, vars, file, interpolated)...)
+		}
+
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			uses = append(uses, interpolateVars(node.Content[i+1], vars, file, interpolated)...)
+		}
+
+	case yaml.ScalarNode:
+		uses = interpolateScalar(node, vars, file, interpolated)
+	}
+
+	return uses
+}
+
+func interpolateScalar(
+	node *yaml.Node,
+	vars map[string]interface{},
+	file string,
+	interpolated map[*yaml.Node]bool,
+) []varUse {
+	var tokens []varToken
+	for _, token := range tokenizeVars(node.Value) {
+		if token.ref.source == "" {
+			tokens = append(tokens, token)
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	uses := make([]varUse, len(tokens))
+	values := make([]interface{}, len(tokens))
+	for i, token := range tokens {
+		values[i], uses[i].defined = lookupVar(vars, token.ref)
+		uses[i].ref = token.ref
+		uses[i].Position = nodePosition(file, node)
+	}
+
+	if len(tokens) == 1 && tokens[0].start == 0 && tokens[0].end == len(node.Value) {
+		if uses[0].defined {
+			var replacement yaml.Node
+			if err := replacement.Encode(values[0]); err == nil {
+				setPosition(&replacement, node.Line, node.Column)
+				replacement.HeadComment = node.HeadComment
+				replacement.LineComment = node.LineComment
+				replacement.FootComment = node.FootComment
+				*node = replacement
+				markInterpolated(node, interpolated)
+			}
+		}
+
+		return uses
+	}
+
+	var value strings.Builder
+	last := 0
+	for i, token := range tokens {
+		value.WriteString(node.Value[last:token.start])
+		last = token.end
+
+		switch values[i].(type) {
+		case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+			uses[i].invalid = true
+		}
+
+		if uses[i].defined && !uses[i].invalid {
+			value.WriteString(fmt.Sprint(values[i]))
+		} else {
+			value.WriteString(node.Value[token.start:token.end])
+		}
+	}
+	value.WriteString(node.Value[last:])
+
+	node.Value = value.String()
+
+	return uses
+}
+
+// taskVarUses returns the references to vars given to testpipe in the values
+// of a task file.
+func taskVarUses(tasks *TaskCache, path string, vars map[string]interface{}) []varUse {
+	_, root, err := tasks.load(path)
+	if err != nil {
+		return nil
+	}
+
+	uses := nodeVarUses(root, vars, path)
+	sortVarUses(uses)
+
+	return uses
+}
+
+// nodeVarUses returns the references to vars given to testpipe in the values
+// of a parsed file, without interpolating them.
+func nodeVarUses(
+	node *yaml.Node,
+	vars map[string]interface{},
+	file string,
+) []varUse {
+	if node == nil {
+		return nil
+	}
+
+	var uses []varUse
+
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, item := range node.Content {
+			uses = append(uses, nodeVarUses(item, vars, file)...)
+		}
+
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			uses = append(uses, nodeVarUses(node.Content[i+1], vars, file)...)
+		}
+
+	case yaml.ScalarNode:
+		for _, token := range tokenizeVars(node.Value) {
+			if token.ref.source != "" {
+				continue
+			}
+
+			_, ok := lookupVar(vars, token.ref)
+			uses = append(uses, varUse{
+				ref:      token.ref,
+				Position: nodePosition(file, node),
+				defined:  ok,
+			})
+		}
+	}
+
+	return uses
+}
+
+// lookupVar returns the value of a var reference, following its fields into
+// map values.
+func lookupVar(vars map[string]interface{}, ref varReference) (interface{}, bool) {
+	value, ok := vars[ref.path]
+	for _, field := range ref.fields {
+		if !ok {
+			break
+		}
+
+		switch m := value.(type) {
+		case map[string]interface{}:
+			value, ok = m[field]
+		case map[interface{}]interface{}:
+			value, ok = m[field]
+		default:
+			ok = false
+		}
+	}
+
+	return value, ok
+}
+
+// sortVarUses orders uses within a file by where they were found.
+func sortVarUses(uses []varUse) {
+	sort.SliceStable(uses, func(i, j int) bool {
+		if uses[i].Line != uses[j].Line {
+			return uses[i].Line < uses[j].Line
+		}
+		return uses[i].Column < uses[j].Column
+	})
+}
+
+// testDefinitionOfVars reports each var referenced without a value, at its
+// first reference.
+func testDefinitionOfVars(uses []varUse, pipelinePath string) []Finding {
+	var findings []Finding
+
+	reported := map[string]bool{}
+	for _, use := range uses {
+		name := use.ref.name()
+		if use.defined || reported[name] {
+			continue
+		}
+		reported[name] = true
+
+		findings = append(findings, Finding{
+			Rule:         RuleUndefinedVars,
+			Kind:         "vars",
+			PipelinePath: pipelinePath,
+			Position:     use.Position,
+			Detail:       "Pipeline refers to vars that are not provided",
+			Missing:      []string{name},
+		})
+	}
+
+	return findings
+}
+
+// testInterpolationOfVars reports each var with a map or list value that is
+// interpolated into a larger string.
+func testInterpolationOfVars(uses []varUse, pipelinePath string) []Finding {
+	var findings []Finding
+
+	for _, use := range uses {
+		if !use.invalid {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:         RuleVarInterpolation,
+			Kind:         "vars",
+			PipelinePath: pipelinePath,
+			Position:     use.Position,
+			Detail:       "Vars with a map or list value are interpolated into a string",
+			Extras:       []string{use.ref.name()},
+		})
+	}
+
+	return findings
+}
+
+// varNames returns the names of the vars uses refer to, each only once.
+func varNames(uses []varUse) []string {
+	var names []string
+	for _, use := range uses {
+		names = appendUnique(names, use.ref.path)
+	}
+
+	return names
+}
+
+// UnusedSharedVars reports the shared vars of config that none of the
+// pipelines linted with it refer to, given the VarsUsed of each of them.
+// The finding is reported for pipelinePath, which should be the first of
+// the pipelines.
+func UnusedSharedVars(config Config, used []string, pipelinePath string) []Finding {
+	return configureFindings(testUsageOfVars(config.SharedVars, used, pipelinePath), config.Rules)
+}
+
+// testUsageOfVars reports vars that are provided but not among those used by
+// the pipeline and the task files it loads. Only the pipeline's own vars are
+// reported, since shared vars may be used by other pipelines.
+func testUsageOfVars(
+	vars map[string]interface{},
+	used []string,
+	pipelinePath string,
+) []Finding {
+	var extras []string
+	for name := range vars {
+		if !contains(used, name) {
+			extras = append(extras, name)
+		}
+	}
+
+	if len(extras) == 0 {
+		return nil
+	}
+
+	sort.Strings(extras)
+
+	return []Finding{{
+		Rule:         RuleUnusedVars,
+		Kind:         "vars",
+		PipelinePath: pipelinePath,
+		Position:     Position{File: pipelinePath},
+		Detail:       "Vars are provided but not used",
+		Extras:       extras,
+	}}
+}
+
+// markInterpolated adds node, and everything in it, to interpolated.
+func markInterpolated(node *yaml.Node, interpolated map[*yaml.Node]bool) {
+	interpolated[node] = true
+	for _, child := range node.Content {
+		markInterpolated(child, interpolated)
+	}
+}
+
+// offsetPosition returns the 1-based line and column of a byte offset.
+func offsetPosition(bs []byte, offset int) (int, int) {
+	line := 1 + bytes.Count(bs[:offset], []byte("\n"))
+	column := offset - bytes.LastIndexByte(bs[:offset], '\n')
+	return line, column
+}
+
+// setPosition gives an encoded node, and everything in it, the position of
+// the node it replaces.
+func setPosition(node *yaml.Node, line int, column int) {
+	node.Line = line
+	node.Column = column
+	for _, child := range node.Content {
+		setPosition(child, line, column)
+	}
+}
@@ -0,0 +1,108 @@
+package testpipe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/concourse/atc"
+)
+
+// workspaceResourceMap maps the git resources a pipeline declares to the
+// repositories checked out in a workspace, adding to resourceMap, which
+// takes precedence. A resource is matched to a directory named after the
+// repository in its uri, or failing that after the resource itself, either
+// directly in the workspace or one directory down.
+func workspaceResourceMap(
+	config atc.Config,
+	workspace string,
+	resourceMap map[string]string,
+) map[string]string {
+	result := make(map[string]string, len(resourceMap))
+	for k, v := range resourceMap {
+		result[k] = v
+	}
+
+	dirs := workspaceDirs(workspace)
+
+	for _, resource := range config.Resources {
+		if resource.Type != "git" {
+			continue
+		}
+
+		if _, ok := result[resource.Name]; ok {
+			continue
+		}
+
+		uri, _ := resource.Source["uri"].(string)
+		for _, name := range []string{repoName(uri), resource.Name} {
+			if dir, ok := dirs[name]; ok && name != "" {
+				result[resource.Name] = dir
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// workspaceDirs returns the directories directly in the workspace and one
+// directory down by name, preferring those closest to the workspace.
+func workspaceDirs(workspace string) map[string]string {
+	dirs := map[string]string{}
+
+	var nested []string
+	for _, dir := range subdirs(workspace) {
+		name := filepath.Base(dir)
+		dirs[name] = dir
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+			nested = append(nested, subdirs(dir)...)
+		}
+	}
+
+	for _, dir := range nested {
+		name := filepath.Base(dir)
+		if _, ok := dirs[name]; !ok {
+			dirs[name] = dir
+		}
+	}
+
+	return dirs
+}
+
+// subdirs returns the directories in dir, leaving out hidden ones.
+func subdirs(dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, info := range infos {
+		if info.IsDir() && !strings.HasPrefix(info.Name(), ".") {
+			dirs = append(dirs, filepath.Join(dir, info.Name()))
+		}
+	}
+
+	return dirs
+}
+
+// repoName returns the name of the repository a git uri points at, e.g.
+// some-repo for https://github.com/some-org/some-repo.git or
+// git@github.com:some-org/some-repo.git.
+func repoName(uri string) string {
+	uri = strings.TrimSuffix(strings.TrimSuffix(uri, "/"), ".git")
+
+	if i := strings.LastIndexAny(uri, "/:"); i >= 0 {
+		uri = uri[i+1:]
+	}
+
+	// An unresolved ((var)) says nothing about the repository.
+	if strings.ContainsAny(uri, "()") {
+		return ""
+	}
+
+	return uri
+}